@@ -1,6 +1,6 @@
 module fiber-advanced-example
 
-go 1.16
+go 1.19
 
 require (
 	github.com/RAuth-IO/rauth-provider-go v1.0.0
@@ -1,3 +1,3 @@
 module github.com/RAuth-IO/rauth-provider-go
 
-go 1.16
+go 1.19
@@ -6,14 +6,24 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/phone"
 )
 
 // WebhookHandler implements the domain.WebhookHandler interface
 type WebhookHandler struct {
 	webhookSecret  string
 	sessionService domain.SessionService
+	inFlight       sync.WaitGroup
+	closed         bool
+	mutex          sync.RWMutex
+
+	positions      map[string]trackedPosition // session token -> latest event
+	lastPrune      time.Time
+	positionsMutex sync.Mutex
 }
 
 // NewWebhookHandler creates a new webhook handler
@@ -21,34 +31,221 @@ func NewWebhookHandler(webhookSecret string, sessionService domain.SessionServic
 	return &WebhookHandler{
 		webhookSecret:  webhookSecret,
 		sessionService: sessionService,
+		positions:      make(map[string]trackedPosition),
 	}
 }
 
-// ProcessWebhook processes incoming webhook events (Node.js compatible)
-func (h *WebhookHandler) ProcessWebhook(ctx context.Context, event *domain.WebhookEvent) error {
-	// Use Event field (Node.js compatible) with fallback to Type (legacy)
-	eventType := event.Event
-	if eventType == "" {
-		eventType = event.Type
+// orderingWindow is how long the latest event of a session token is remembered
+// to recognise events delivered late or more than once
+const orderingWindow = 24 * time.Hour
+
+// eventPosition orders the events of a session token by timestamp, then sequence
+type eventPosition struct {
+	timestamp int64
+	sequence  int64
+}
+
+// after reports whether p comes strictly after other
+func (p eventPosition) after(other eventPosition) bool {
+	if p.timestamp != other.timestamp {
+		return p.timestamp > other.timestamp
+	}
+	return p.sequence > other.sequence
+}
+
+// trackedPosition is the latest event position seen for a session token
+type trackedPosition struct {
+	position eventPosition
+	seenAt   time.Time
+}
+
+// isLatest reports whether a session token event comes after every event
+// applied for that token. Events without a timestamp or sequence cannot be
+// ordered and are always reported as the latest.
+func (h *WebhookHandler) isLatest(event *domain.WebhookEvent) bool {
+	if event.Timestamp == 0 && event.Sequence == 0 {
+		return true
+	}
+	position := eventPosition{timestamp: event.Timestamp, sequence: event.Sequence}
+
+	h.positionsMutex.Lock()
+	defer h.positionsMutex.Unlock()
+
+	tracked, exists := h.positions[event.SessionToken]
+	return !exists || position.after(tracked.position)
+}
+
+// record remembers the position of a session token event once it has been
+// applied, so that it is not applied again when delivered late or twice.
+// Events that failed are not recorded and are applied when Rauth redelivers them.
+func (h *WebhookHandler) record(event *domain.WebhookEvent) {
+	if event.Timestamp == 0 && event.Sequence == 0 {
+		return
+	}
+	position := eventPosition{timestamp: event.Timestamp, sequence: event.Sequence}
+
+	h.positionsMutex.Lock()
+	defer h.positionsMutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.lastPrune) > time.Minute {
+		for token, tracked := range h.positions {
+			if now.Sub(tracked.seenAt) > orderingWindow {
+				delete(h.positions, token)
+			}
+		}
+		h.lastPrune = now
+	}
+
+	if tracked, exists := h.positions[event.SessionToken]; exists && !position.after(tracked.position) {
+		return
+	}
+	h.positions[event.SessionToken] = trackedPosition{position: position, seenAt: now}
+}
+
+// Webhook event types
+const (
+	EventSessionCreated     = "session_created"
+	EventSessionRevoked     = "session_revoked"
+	EventAllSessionsRevoked = "all_sessions_revoked"
+	EventRevocationEpoch    = "revocation_epoch"
+)
+
+// eventType returns the event type, using Event (Node.js compatible) with fallback to Type (legacy)
+func eventType(event *domain.WebhookEvent) string {
+	if event.Event != "" {
+		return event.Event
+	}
+	return event.Type
+}
+
+// eventPhone returns the event phone, using Phone (Node.js compatible) with fallback to UserPhone (legacy)
+func eventPhone(event *domain.WebhookEvent) string {
+	if event.Phone != "" {
+		return event.Phone
+	}
+	return event.UserPhone
+}
+
+// eventTime returns when the event happened, zero when it carries no timestamp
+func eventTime(event *domain.WebhookEvent) time.Time {
+	if event.Timestamp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(event.Timestamp, 0)
+}
+
+// eventTTL returns the event TTL, zero when the event carries none
+func eventTTL(event *domain.WebhookEvent) time.Duration {
+	if event.TTL <= 0 {
+		return 0
 	}
+	return time.Duration(event.TTL) * time.Second
+}
 
-	switch eventType {
-	case "session_created":
-		// Session was created, no action needed as it's handled during verification
+// ProcessWebhook processes incoming webhook events (Node.js compatible)
+func (h *WebhookHandler) ProcessWebhook(ctx context.Context, event *domain.WebhookEvent) error {
+	switch eventType := eventType(event); eventType {
+	case EventSessionCreated:
+		// Session was created, cache it so that its first verification needs no API call
+		if !h.isLatest(event) {
+			// Delivered late or again, a newer event of the session was already applied
+			return nil
+		}
+		// The token may have been checked before it was verified
+		h.sessionService.ForgetUnverified(event.SessionToken)
+		phone := eventPhone(event)
+		if phone == "" {
+			// Without a phone the session is cached on its first verification
+			h.record(event)
+			return nil
+		}
+		if err := h.sessionService.CacheSession(ctx, event.SessionToken, phone, eventTime(event), eventTTL(event)); err != nil {
+			return err
+		}
+		h.record(event)
+		return nil
+	case EventSessionRevoked:
+		// Session was revoked, add to revoked sessions for the TTL of the event.
+		// Revocations are final, so they are applied even when delivered late.
+		if err := h.sessionService.RevokeSession(ctx, event.SessionToken, domain.Revocation{
+			Source: domain.RevocationSourceWebhook,
+			Reason: event.Reason,
+			TTL:    eventTTL(event),
+		}); err != nil {
+			return err
+		}
+		h.record(event)
 		return nil
-	case "session_revoked":
-		// Session was revoked, add to revoked sessions
-		return h.sessionService.RevokeSession(ctx, event.SessionToken)
+	case EventAllSessionsRevoked:
+		// Every session of a phone number was revoked
+		phone := eventPhone(event)
+		if phone == "" {
+			return &domain.ValidationError{Field: "phone", Message: "phone is required"}
+		}
+		_, err := h.sessionService.RevokeAllForPhone(ctx, phone, domain.Revocation{
+			Source: domain.RevocationSourceWebhook,
+			Reason: event.Reason,
+			TTL:    eventTTL(event),
+		})
+		return err
+	case EventRevocationEpoch:
+		// Every session created before not_before was revoked, globally or for one phone.
+		// An epoch slightly ahead of the local clock is skew, not an error to redeliver.
+		notBefore := time.Now()
+		if event.NotBefore > 0 && time.Unix(event.NotBefore, 0).Before(notBefore) {
+			notBefore = time.Unix(event.NotBefore, 0)
+		}
+		return h.sessionService.SetRevocationEpoch(ctx, eventPhone(event), notBefore)
 	default:
 		return fmt.Errorf("unknown webhook event type: %s", eventType)
 	}
 }
 
+// Shutdown stops accepting new webhook requests and waits for in-flight ones to finish
+func (h *WebhookHandler) Shutdown(ctx context.Context) error {
+	h.mutex.Lock()
+	h.closed = true
+	h.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// begin registers an in-flight request, returning false once the handler is shut down
+func (h *WebhookHandler) begin() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.closed {
+		return false
+	}
+	h.inFlight.Add(1)
+	return true
+}
+
 // HTTPHandler returns an http.HandlerFunc for processing webhook requests
 func (h *WebhookHandler) HTTPHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
+		// Reject requests once shutdown has started
+		if !h.begin() {
+			http.Error(w, "Webhook handler is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer h.inFlight.Done()
+
 		// Only allow POST requests
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -89,9 +286,34 @@ func (h *WebhookHandler) HTTPHandler() http.HandlerFunc {
 			return
 		}
 
-		if event.SessionToken == "" {
-			http.Error(w, "Missing session_token", http.StatusBadRequest)
-			return
+		switch eventType(&event) {
+		case EventAllSessionsRevoked:
+			if eventPhone(&event) == "" {
+				http.Error(w, "Missing phone", http.StatusBadRequest)
+				return
+			}
+		case EventRevocationEpoch:
+			// Phone is optional, an epoch without one applies to every session
+		default:
+			if event.SessionToken == "" {
+				http.Error(w, "Missing session_token", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Normalize the phone to E.164 so it matches the cached sessions. Only the
+		// events using the phone need a valid one: a revocation is never dropped
+		// because of a field it ignores.
+		switch eventType(&event) {
+		case EventSessionCreated, EventAllSessionsRevoked, EventRevocationEpoch:
+			if number := eventPhone(&event); number != "" {
+				normalized, err := phone.Normalize(number)
+				if err != nil {
+					http.Error(w, "Invalid phone", http.StatusBadRequest)
+					return
+				}
+				event.Phone = normalized
+			}
 		}
 
 		// Process the webhook event
@@ -0,0 +1,196 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/internal/infrastructure"
+	"github.com/RAuth-IO/rauth-provider-go/internal/usecase"
+)
+
+// unreachableAPIClient fails every verification, so tests only see cached sessions
+type unreachableAPIClient struct{}
+
+func (unreachableAPIClient) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	return &domain.SessionDetails{Token: sessionToken, Status: domain.SessionStatusNotFound}, nil
+}
+
+func (unreachableAPIClient) CheckHealth(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// newTestHandler creates a webhook handler backed by in-memory stores
+func newTestHandler() (*WebhookHandler, *usecase.SessionService) {
+	sessionService := usecase.NewSessionService(
+		infrastructure.NewSessionStore(),
+		infrastructure.NewRevokedSessionStore(),
+		unreachableAPIClient{},
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600},
+	)
+	return NewWebhookHandler("secret", sessionService), sessionService
+}
+
+func TestProcessWebhook_LateSessionCreatedIsIgnored(t *testing.T) {
+	h, sessionService := newTestHandler()
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	revoked := &domain.WebhookEvent{Event: EventSessionRevoked, SessionToken: "token-1", Timestamp: now, Sequence: 2}
+	created := &domain.WebhookEvent{Event: EventSessionCreated, SessionToken: "token-1", Phone: "+1234567890", TTL: 3600, Timestamp: now, Sequence: 1}
+	for _, event := range []*domain.WebhookEvent{revoked, created} {
+		if err := h.ProcessWebhook(ctx, event); err != nil {
+			t.Fatalf("ProcessWebhook(%s) returned error: %v", event.Event, err)
+		}
+	}
+
+	if _, err := sessionService.GetSession(ctx, "token-1"); err != domain.ErrSessionNotFound {
+		t.Errorf("A late session_created should not cache a revoked session, got %v", err)
+	}
+	if h.isLatest(created) {
+		t.Error("Events older than the latest one of a token should be reported as stale")
+	}
+}
+
+// failingSessionService fails the first CacheSession calls, like a store that is briefly down
+type failingSessionService struct {
+	*usecase.SessionService
+	failures int
+	calls    int
+}
+
+func (s *failingSessionService) CacheSession(ctx context.Context, sessionToken, userPhone string, createdAt time.Time, ttl time.Duration) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("store unavailable")
+	}
+	return s.SessionService.CacheSession(ctx, sessionToken, userPhone, createdAt, ttl)
+}
+
+func TestProcessWebhook_RedeliveryAfterFailureIsApplied(t *testing.T) {
+	_, sessionService := newTestHandler()
+	failing := &failingSessionService{SessionService: sessionService, failures: 1}
+	h := NewWebhookHandler("secret", failing)
+	ctx := context.Background()
+
+	created := &domain.WebhookEvent{Event: EventSessionCreated, SessionToken: "token-1", Phone: "+1234567890", TTL: 3600, Timestamp: time.Now().Unix(), Sequence: 1}
+	if err := h.ProcessWebhook(ctx, created); err == nil {
+		t.Fatal("Expected the first delivery to fail")
+	}
+	if err := h.ProcessWebhook(ctx, created); err != nil {
+		t.Fatalf("ProcessWebhook returned error on redelivery: %v", err)
+	}
+
+	if failing.calls != 2 {
+		t.Errorf("Expected the redelivery to be applied, got %d CacheSession calls", failing.calls)
+	}
+	if _, err := sessionService.GetSession(ctx, "token-1"); err != nil {
+		t.Errorf("Expected the session to be cached after the redelivery, got %v", err)
+	}
+
+	// Once applied, the event is a duplicate
+	if err := h.ProcessWebhook(ctx, created); err != nil || failing.calls != 2 {
+		t.Errorf("Expected the duplicate to be acknowledged without being applied, got %v after %d calls", err, failing.calls)
+	}
+}
+
+func TestProcessWebhook_ExpiredSessionCreatedIsIgnored(t *testing.T) {
+	h, sessionService := newTestHandler()
+	ctx := context.Background()
+
+	// Delivered two hours after the one-hour session ended
+	created := &domain.WebhookEvent{Event: EventSessionCreated, SessionToken: "token-1", Phone: "+1234567890", TTL: 3600, Timestamp: time.Now().Add(-3 * time.Hour).Unix()}
+	if err := h.ProcessWebhook(ctx, created); err != nil {
+		t.Fatalf("ProcessWebhook returned error: %v", err)
+	}
+
+	if _, err := sessionService.GetSession(ctx, "token-1"); err != domain.ErrSessionNotFound {
+		t.Errorf("Sessions that already ended should not be cached, got %v", err)
+	}
+}
+
+func TestProcessWebhook_SessionCreatedForgetsUnverifiedToken(t *testing.T) {
+	sessionService := usecase.NewSessionService(
+		infrastructure.NewSessionStore(),
+		infrastructure.NewRevokedSessionStore(),
+		unreachableAPIClient{},
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, NegativeCacheTTL: 30, NegativeCacheSize: 10},
+	)
+	h := NewWebhookHandler("secret", sessionService)
+	ctx := context.Background()
+
+	if verified, _ := sessionService.VerifySession(ctx, "token-1", ""); verified {
+		t.Fatal("Expected the unknown token to be rejected")
+	}
+	if entries := sessionService.NegativeCacheStats().Entries; entries != 1 {
+		t.Fatalf("Expected the token in the negative cache, got %d entries", entries)
+	}
+
+	// Even without a phone to cache the session with
+	created := &domain.WebhookEvent{Event: EventSessionCreated, SessionToken: "token-1", Timestamp: time.Now().Unix()}
+	if err := h.ProcessWebhook(ctx, created); err != nil {
+		t.Fatalf("ProcessWebhook returned error: %v", err)
+	}
+	if entries := sessionService.NegativeCacheStats().Entries; entries != 0 {
+		t.Errorf("Expected session_created to remove the token from the negative cache, got %d entries", entries)
+	}
+}
+
+func TestProcessWebhook_FutureEpochIsClampedToNow(t *testing.T) {
+	h, sessionService := newTestHandler()
+	ctx := context.Background()
+
+	if err := sessionService.CacheSession(ctx, "token-1", "+1234567890", time.Now().Add(-time.Hour), 2*time.Hour); err != nil {
+		t.Fatalf("CacheSession returned error: %v", err)
+	}
+
+	// The sender's clock runs ahead of ours
+	epoch := &domain.WebhookEvent{Event: EventRevocationEpoch, NotBefore: time.Now().Add(30 * time.Second).Unix()}
+	if err := h.ProcessWebhook(ctx, epoch); err != nil {
+		t.Fatalf("ProcessWebhook returned error: %v", err)
+	}
+	if verified, err := sessionService.VerifySession(ctx, "token-1", "+1234567890"); verified || err != domain.ErrSessionRevoked {
+		t.Errorf("Expected the epoch to revoke older sessions, got %v, %v", verified, err)
+	}
+}
+
+func TestHTTPHandler_NormalizesPhone(t *testing.T) {
+	h, sessionService := newTestHandler()
+	ctx := context.Background()
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		req.Header.Set("x-webhook-secret", "secret")
+		rec := httptest.NewRecorder()
+		h.HTTPHandler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(`{"event":"session_created","session_token":"token-1","phone":"+1 (234) 567-890","ttl":3600}`); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	session, err := sessionService.GetSession(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if session.UserPhone != "+1234567890" {
+		t.Errorf("Expected the phone in E.164 format, got %q", session.UserPhone)
+	}
+
+	if code := post(`{"event":"session_created","session_token":"token-2","phone":"234-567-890","ttl":3600}`); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid phone, got %d", http.StatusBadRequest, code)
+	}
+
+	// Revocations do not use the phone and are applied whatever its format
+	if code := post(`{"event":"session_revoked","session_token":"token-1","phone":"234-567-890"}`); code != http.StatusOK {
+		t.Errorf("Expected status %d for a revocation with an invalid phone, got %d", http.StatusOK, code)
+	}
+	if revoked, _ := sessionService.IsSessionRevoked(ctx, "token-1"); !revoked {
+		t.Error("Expected the revocation to be applied")
+	}
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+)
+
+// metadataKey is the context key for SessionMetadata
+type metadataKey struct{}
+
+// expiryPolicyKey is the context key for ExpiryPolicy
+type expiryPolicyKey struct{}
+
+// ContextWithMetadata returns a context carrying metadata to record on sessions verified with it
+func ContextWithMetadata(ctx context.Context, metadata SessionMetadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// MetadataFromContext returns the session metadata carried by ctx, if any
+func MetadataFromContext(ctx context.Context) (SessionMetadata, bool) {
+	metadata, ok := ctx.Value(metadataKey{}).(SessionMetadata)
+	return metadata, ok
+}
+
+// ContextWithExpiryPolicy returns a context carrying an expiry policy that overrides
+// the configured one for sessions checked with it. Zero fields keep the configured values.
+func ContextWithExpiryPolicy(ctx context.Context, policy ExpiryPolicy) context.Context {
+	return context.WithValue(ctx, expiryPolicyKey{}, policy)
+}
+
+// ExpiryPolicyFromContext returns the expiry policy carried by ctx, if any
+func ExpiryPolicyFromContext(ctx context.Context) (ExpiryPolicy, bool) {
+	policy, ok := ctx.Value(expiryPolicyKey{}).(ExpiryPolicy)
+	return policy, ok
+}
@@ -10,6 +10,52 @@ type Session struct {
 	UserPhone string    `json:"user_phone"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+
+	// VerifiedAt is when the session was last verified with the Rauth API;
+	// the absolute session lifetime counts from it
+	VerifiedAt time.Time `json:"verified_at"`
+	// UpstreamExpiresAt is when the Rauth API said the session ends, zero when unknown;
+	// the session is never served from the cache past it
+	UpstreamExpiresAt time.Time `json:"upstream_expires_at"`
+
+	SessionMetadata
+}
+
+// SessionMetadata holds optional details recorded when a session is first verified
+type SessionMetadata struct {
+	ClientIP    string    `json:"client_ip,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	DeviceLabel string    `json:"device_label,omitempty"`
+	Channel     string    `json:"channel,omitempty"` // ChannelWhatsApp or ChannelSMS
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// Verification channels reported for a session
+const (
+	ChannelWhatsApp = "whatsapp"
+	ChannelSMS      = "sms"
+)
+
+// SessionStatus is the state of a session as reported by the Rauth API
+type SessionStatus string
+
+// Session statuses reported by the Rauth API
+const (
+	SessionStatusVerified SessionStatus = "verified"  // the user completed the verification
+	SessionStatusPending  SessionStatus = "pending"   // the verification has not been completed yet
+	SessionStatusExpired  SessionStatus = "expired"   // the session ended
+	SessionStatusRevoked  SessionStatus = "revoked"   // the session was revoked
+	SessionStatusNotFound SessionStatus = "not_found" // the API does not know the token
+)
+
+// SessionDetails describes a session as reported by the Rauth API
+type SessionDetails struct {
+	Token     string        `json:"session_token"`
+	Status    SessionStatus `json:"status"`
+	Phone     string        `json:"phone,omitempty"`
+	Channel   string        `json:"channel,omitempty"` // ChannelWhatsApp or ChannelSMS
+	CreatedAt time.Time     `json:"created_at"`        // zero when not reported
+	ExpiresAt time.Time     `json:"expires_at"`        // zero when not reported
 }
 
 // RevokedSession represents a revoked session
@@ -17,6 +63,37 @@ type RevokedSession struct {
 	Token     string    `json:"token"`
 	RevokedAt time.Time `json:"revoked_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+	Source    string    `json:"source,omitempty"` // one of the RevocationSource constants
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Revocation describes why and by whom sessions are revoked
+type Revocation struct {
+	Source string        // one of the RevocationSource constants
+	Reason string        // free-form explanation, e.g. the reason of a webhook event
+	TTL    time.Duration // minimum time to keep the revocation, 0 for the default revoked TTL
+}
+
+// Sources of a revocation
+const (
+	RevocationSourceWebhook    = "webhook"     // revoked by Rauth through a webhook
+	RevocationSourceAdmin      = "admin"       // revoked by an administrator of the application
+	RevocationSourceUserLogout = "user_logout" // the user signed out, possibly on another device
+	RevocationSourceEviction   = "eviction"    // evicted to stay within MaxSessionsPerPhone
+	RevocationSourceEpoch      = "epoch"       // created before a revocation epoch
+)
+
+// RevocationMessage describes a revocation propagated between provider instances
+type RevocationMessage struct {
+	Origin    string    `json:"origin"` // ID of the publishing instance
+	Token     string    `json:"token,omitempty"`
+	Phone     string    `json:"phone,omitempty"`  // set when every session of a phone was revoked
+	Tokens    []string  `json:"tokens,omitempty"` // tokens the publisher revoked for Phone
+	NotBefore time.Time `json:"not_before"`       // set for a revocation epoch of Phone, or global when Phone is empty
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Source    string    `json:"source,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
 }
 
 // Config holds the configuration for RauthProvider
@@ -26,21 +103,54 @@ type Config struct {
 	WebhookSecret     string `json:"webhook_secret"`
 	DefaultSessionTTL int    `json:"default_session_ttl"` // in seconds
 	DefaultRevokedTTL int    `json:"default_revoked_ttl"` // in seconds
+
+	MaxSessionsPerPhone int    `json:"max_sessions_per_phone"` // 0 means unlimited
+	SessionLimitPolicy  string `json:"session_limit_policy"`   // SessionLimitReject or SessionLimitEvictOldest
+
+	IdleTimeout        int `json:"idle_timeout"`         // in seconds, 0 disables the sliding timeout
+	MaxSessionLifetime int `json:"max_session_lifetime"` // in seconds
+
+	DegradedMode        string `json:"degraded_mode"`         // DegradedModeFailClosed or DegradedModeFailOpen
+	DegradedGracePeriod int    `json:"degraded_grace_period"` // in seconds, how recently a session must have been verified to fail open
+
+	NegativeCacheTTL  int `json:"negative_cache_ttl"`  // in seconds, 0 disables caching unknown, expired and revoked tokens
+	PendingCacheTTL   int `json:"pending_cache_ttl"`   // in seconds, 0 disables caching pending tokens
+	NegativeCacheSize int `json:"negative_cache_size"` // maximum number of tokens in the negative cache
 }
 
+// ExpiryPolicy controls how long a verified session is served from the local cache
+type ExpiryPolicy struct {
+	IdleTimeout time.Duration // sliding timeout extended on each check, 0 disables it
+	MaxLifetime time.Duration // absolute time after verification when the API is asked again
+}
+
+// Session limit policies applied when a phone reaches MaxSessionsPerPhone
+const (
+	SessionLimitReject      = "reject"       // refuse the new session
+	SessionLimitEvictOldest = "evict_oldest" // revoke the oldest sessions to make room
+)
+
+// Degraded modes applied when the Rauth API is unavailable
+const (
+	DegradedModeFailClosed = "fail_closed" // reject sessions that need the API
+	DegradedModeFailOpen   = "fail_open"   // accept cached sessions verified within the grace period
+)
+
 // WebhookEvent represents a webhook event from Rauth.io (Node.js compatible)
 type WebhookEvent struct {
-	Event        string `json:"event"`        // Node.js uses "event" instead of "type"
+	Event        string `json:"event"` // Node.js uses "event" instead of "type"
 	SessionToken string `json:"session_token"`
-	Phone        string `json:"phone"`        // Node.js uses "phone" instead of "user_phone"
-	TTL          int    `json:"ttl"`          // Node.js uses "ttl" instead of "timestamp"
-	Reason       string `json:"reason"`       // Node.js specific field
+	Phone        string `json:"phone"`                // Node.js uses "phone" instead of "user_phone"
+	TTL          int    `json:"ttl"`                  // Node.js uses "ttl" instead of "timestamp"
+	Reason       string `json:"reason"`               // Node.js specific field
+	NotBefore    int64  `json:"not_before,omitempty"` // Unix seconds, for revocation_epoch events
+	Sequence     int64  `json:"sequence,omitempty"`   // orders events with the same timestamp
 	Signature    string `json:"signature"`
-	
+
 	// Legacy fields for backward compatibility
-	Type         string `json:"type,omitempty"`
-	UserPhone    string `json:"user_phone,omitempty"`
-	Timestamp    int64  `json:"timestamp,omitempty"`
+	Type      string `json:"type,omitempty"`
+	UserPhone string `json:"user_phone,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"` // Unix seconds when the event happened
 }
 
 // APIResponse represents a response from the Rauth API
@@ -7,14 +7,16 @@ import (
 
 // Common errors
 var (
-	ErrNotInitialized     = errors.New("rauth provider not initialized")
-	ErrInvalidConfig      = errors.New("invalid configuration")
-	ErrSessionNotFound    = errors.New("session not found")
-	ErrSessionExpired     = errors.New("session expired")
-	ErrSessionRevoked     = errors.New("session revoked")
-	ErrInvalidSignature   = errors.New("invalid signature")
-	ErrAPIUnreachable     = errors.New("rauth API unreachable")
-	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+	ErrNotInitialized       = errors.New("rauth provider not initialized")
+	ErrInvalidConfig        = errors.New("invalid configuration")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrSessionExpired       = errors.New("session expired")
+	ErrSessionRevoked       = errors.New("session revoked")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrAPIUnreachable       = errors.New("rauth API unreachable")
+	ErrInvalidPhoneNumber   = errors.New("invalid phone number")
+	ErrSessionLimitExceeded = errors.New("maximum sessions per phone exceeded")
+	ErrCircuitOpen          = fmt.Errorf("%w: circuit breaker open", ErrAPIUnreachable)
 )
 
 // ConfigError represents configuration-related errors
@@ -45,6 +47,20 @@ func (e *APIError) Unwrap() error {
 	return ErrAPIUnreachable
 }
 
+// IsAPIUnavailable reports whether err means the Rauth API could not answer:
+// the circuit breaker is open, or the request failed in transit, was rate limited
+// or hit a server error. Other API errors, such as 400 or 403, are answers.
+func IsAPIUnavailable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == 0 || apiErr.StatusCode == 429 || apiErr.StatusCode >= 500
+	}
+	return false
+}
+
 // ValidationError represents validation errors
 type ValidationError struct {
 	Field   string
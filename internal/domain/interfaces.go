@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 // SessionRepository defines the interface for session storage operations
@@ -15,6 +16,19 @@ type SessionRepository interface {
 	// Delete removes a session
 	Delete(ctx context.Context, token string) error
 
+	// DeleteByPhone removes every session of a phone number and returns their tokens
+	DeleteByPhone(ctx context.Context, phone string) ([]string, error)
+
+	// ListSessions returns the unexpired sessions of a phone number, oldest first
+	ListSessions(ctx context.Context, phone string) ([]*Session, error)
+
+	// CountSessions returns the number of unexpired sessions of a phone number
+	CountSessions(ctx context.Context, phone string) (int, error)
+
+	// Touch records activity on an unexpired session, setting its last seen time
+	// and moving its expiry, and returns ErrSessionNotFound when it is not cached
+	Touch(ctx context.Context, token string, lastSeenAt, expiresAt time.Time) error
+
 	// Cleanup removes expired sessions
 	Cleanup(ctx context.Context) error
 }
@@ -27,14 +41,40 @@ type RevokedSessionRepository interface {
 	// Get retrieves a revoked session by token
 	Get(ctx context.Context, token string) (*RevokedSession, error)
 
+	// SetNotBefore revokes every session of scope created before notBefore.
+	// An empty scope applies to all sessions, otherwise scope is a phone number.
+	// The epoch only moves forward: an earlier notBefore than the stored one is ignored.
+	SetNotBefore(ctx context.Context, scope string, notBefore time.Time) error
+
+	// GetNotBefore returns the epoch of scope, or the zero time when none is set
+	GetNotBefore(ctx context.Context, scope string) (time.Time, error)
+
 	// Cleanup removes expired revoked sessions
 	Cleanup(ctx context.Context) error
 }
 
+// Closer is implemented by repositories that hold resources which must be
+// flushed or released when the provider shuts down
+type Closer interface {
+	// Close flushes pending writes and releases resources
+	Close(ctx context.Context) error
+}
+
+// RevocationBroadcaster propagates revocations to every provider instance
+type RevocationBroadcaster interface {
+	// Publish sends a revocation to all subscribers, including other instances
+	Publish(ctx context.Context, message *RevocationMessage) error
+
+	// Subscribe registers a handler for published revocations.
+	// The returned function stops the subscription and waits for it to finish.
+	Subscribe(ctx context.Context, handler func(*RevocationMessage)) (func(), error)
+}
+
 // APIClient defines the interface for Rauth API communication
 type APIClient interface {
-	// VerifySession verifies a session with the Rauth API
-	VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, error)
+	// GetSessionDetails returns the status of a session from the Rauth API.
+	// Unknown tokens are reported with SessionStatusNotFound rather than an error.
+	GetSessionDetails(ctx context.Context, sessionToken string) (*SessionDetails, error)
 
 	// CheckHealth checks if the Rauth API is reachable
 	CheckHealth(ctx context.Context) (bool, error)
@@ -54,9 +94,30 @@ type SessionService interface {
 	// VerifySession verifies if a session is valid
 	VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, error)
 
+	// GetSessionDetails returns the status of a session from the Rauth API,
+	// reporting sessions revoked locally as SessionStatusRevoked
+	GetSessionDetails(ctx context.Context, sessionToken string) (*SessionDetails, error)
+
 	// IsSessionRevoked checks if a session has been revoked
 	IsSessionRevoked(ctx context.Context, sessionToken string) (bool, error)
 
-	// RevokeSession revokes a session
-	RevokeSession(ctx context.Context, sessionToken string) error
+	// RevokeSession revokes a session, recording the source and reason of the revocation
+	RevokeSession(ctx context.Context, sessionToken string, revocation Revocation) error
+
+	// GetRevocation returns the revocation of a session, or ErrSessionNotFound
+	GetRevocation(ctx context.Context, sessionToken string) (*RevokedSession, error)
+
+	// CacheSession caches a session that Rauth created for a phone number at createdAt,
+	// expiring it ttl after its creation when ttl is positive
+	CacheSession(ctx context.Context, sessionToken, userPhone string, createdAt time.Time, ttl time.Duration) error
+
+	// ForgetUnverified removes a token from the cache of tokens the API did not verify
+	ForgetUnverified(sessionToken string)
+
+	// RevokeAllForPhone revokes every known session of a phone number
+	RevokeAllForPhone(ctx context.Context, phone string, revocation Revocation) (int, error)
+
+	// SetRevocationEpoch revokes every session created before notBefore,
+	// for one phone number or for all sessions when phone is empty
+	SetRevocationEpoch(ctx context.Context, phone string, notBefore time.Time) error
 }
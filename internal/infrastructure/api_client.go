@@ -3,144 +3,315 @@ package infrastructure
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
 )
 
+const (
+	// DefaultAPIBaseURL is the base URL of the production Rauth session API
+	DefaultAPIBaseURL = "https://api.rauth.io/session"
+	// DefaultAPITimeout bounds each request to the Rauth API when no timeout is configured
+	DefaultAPITimeout = 30 * time.Second
+)
+
+// APIClientConfig configures how an APIClient reaches the Rauth API
+type APIClientConfig struct {
+	// BaseURL of the session API (default: DefaultAPIBaseURL)
+	BaseURL string
+	// HTTPClient sends the requests as is; it cannot be combined with Transport or TLSConfig
+	HTTPClient *http.Client
+	// Transport sends the requests instead of a clone of http.DefaultTransport
+	Transport http.RoundTripper
+	// TLSConfig is used by the default transport
+	TLSConfig *tls.Config
+	// SessionStatusTimeout bounds each /status request (default: DefaultAPITimeout)
+	SessionStatusTimeout time.Duration
+	// HealthCheckTimeout bounds each /health request (default: DefaultAPITimeout)
+	HealthCheckTimeout time.Duration
+	// RetryPolicy decides how failed /status requests are retried (default: DefaultRetryPolicy)
+	RetryPolicy RetryPolicy
+}
+
 // APIClient implements the domain.APIClient interface
 type APIClient struct {
-	baseURL    string
-	apiKey     string
-	appID      string
-	httpClient *http.Client
+	baseURL              string
+	apiKey               string
+	appID                string
+	httpClient           *http.Client
+	ownsHTTPClient       bool
+	sessionStatusTimeout time.Duration
+	healthCheckTimeout   time.Duration
+	retryPolicy          RetryPolicy
 }
 
 // NewAPIClient creates a new API client
-func NewAPIClient(apiKey, appID string) *APIClient {
-	return &APIClient{
-		baseURL: "https://api.rauth.io/session",
-		apiKey:  apiKey,
-		appID:   appID,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+func NewAPIClient(apiKey, appID string, config APIClientConfig) *APIClient {
+	c := &APIClient{
+		baseURL:              strings.TrimRight(config.BaseURL, "/"),
+		apiKey:               apiKey,
+		appID:                appID,
+		httpClient:           config.HTTPClient,
+		sessionStatusTimeout: config.SessionStatusTimeout,
+		healthCheckTimeout:   config.HealthCheckTimeout,
+		retryPolicy:          config.RetryPolicy.withDefaults(),
 	}
-}
-
-// VerifySession verifies a session with the Rauth API
-func (c *APIClient) VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, error) {
-	payload := map[string]interface{}{
-		"session_token": sessionToken,
+	if c.baseURL == "" {
+		c.baseURL = DefaultAPIBaseURL
+	}
+	if c.sessionStatusTimeout <= 0 {
+		c.sessionStatusTimeout = DefaultAPITimeout
+	}
+	if c.healthCheckTimeout <= 0 {
+		c.healthCheckTimeout = DefaultAPITimeout
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return false, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Retry with exponential backoff for Cloudflare challenges
-	maxRetries := 3
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Wait before retry with exponential backoff
-			backoff := time.Duration(attempt) * time.Second
-			select {
-			case <-ctx.Done():
-				return false, ctx.Err()
-			case <-time.After(backoff):
+	// Build a client of our own unless one was supplied; the default transport
+	// honours the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	if c.httpClient == nil {
+		transport := config.Transport
+		if transport == nil {
+			defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+			if config.TLSConfig != nil {
+				defaultTransport.TLSClientConfig = config.TLSConfig
 			}
+			transport = defaultTransport
 		}
+		c.httpClient = &http.Client{Transport: transport}
+		c.ownsHTTPClient = true
+	}
+	return c
+}
+
+// LoadTLSConfig returns a copy of base (or a new configuration) trusting the CA
+// certificates of caFile instead of the system pool and presenting the client
+// certificate of certFile and keyFile. Empty file names are skipped.
+func LoadTLSConfig(base *tls.Config, caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		config = base.Clone()
+	}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/status", bytes.NewBuffer(jsonData))
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
 		if err != nil {
-			return false, fmt.Errorf("failed to create request: %w", err)
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
 		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
-		req.Header.Set("X-App-ID", c.appID)
-		req.Header.Set("User-Agent", "RauthProvider-Go/1.0")
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-
-		resp, err := c.httpClient.Do(req)
+	if certFile != "" || keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
 		if err != nil {
-			return false, &domain.APIError{
-				StatusCode: 0,
-				Message:    fmt.Sprintf("failed to make request: %v", err),
-			}
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
 		}
-		defer resp.Body.Close()
+		config.Certificates = append(config.Certificates, certificate)
+	}
 
-		body, err := io.ReadAll(resp.Body)
+	return config, nil
+}
+
+// sessionStatusResponse is the body of a /status response
+type sessionStatusResponse struct {
+	SessionToken string  `json:"session_token"`
+	Status       string  `json:"status"`
+	Phone        string  `json:"phone"`
+	Channel      string  `json:"channel"`
+	CreatedAt    apiTime `json:"created_at"`
+	ExpiresAt    apiTime `json:"expires_at"`
+}
+
+// apiTime decodes a timestamp sent either as an RFC 3339 string or as a Unix
+// time in seconds or milliseconds
+type apiTime struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *apiTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" || string(data) == `""` {
+		return nil
+	}
+
+	if data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
 		if err != nil {
-			return false, fmt.Errorf("failed to read response body: %w", err)
+			return err
 		}
+		t.Time = parsed
+		return nil
+	}
 
-		if resp.StatusCode == 404 {
-			return false, nil // Session not found
+	var unix int64
+	if err := json.Unmarshal(data, &unix); err != nil {
+		return err
+	}
+	if unix > 1e12 {
+		t.Time = time.Unix(0, unix*int64(time.Millisecond))
+	} else {
+		t.Time = time.Unix(unix, 0)
+	}
+	return nil
+}
+
+// GetSessionDetails returns the status of a session from the Rauth API
+func (c *APIClient) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	payload := map[string]interface{}{
+		"session_token": sessionToken,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Retry transport errors and retryable statuses with exponential backoff,
+	// within the retry budget and the deadline of ctx. The budget bounds the
+	// attempts too, so an attempt in flight when it runs out is cancelled.
+	budgetCtx, cancel := context.WithTimeout(ctx, c.retryPolicy.Budget)
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		statusCode, header, body, err := c.sessionStatus(budgetCtx, jsonData)
+		if err == nil {
+			switch statusCode {
+			case http.StatusOK:
+				return parseSessionDetails(sessionToken, body)
+			case http.StatusNotFound:
+				return &domain.SessionDetails{
+					Token:  sessionToken,
+					Status: domain.SessionStatusNotFound,
+				}, nil
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
 
-		// If we get a 403, retry (Cloudflare challenge)
-		if resp.StatusCode == 403 && attempt < maxRetries-1 {
-			continue
+		lastErr := err
+		if lastErr == nil {
+			lastErr = statusError(statusCode, body)
+		}
+		if attempt >= c.retryPolicy.MaxAttempts || !c.retryPolicy.retryable(statusCode, err) {
+			return nil, lastErr
 		}
 
-		if resp.StatusCode == 403 {
-			return false, &domain.APIError{
-				StatusCode: resp.StatusCode,
-				Message:    "Access denied. This might be due to Cloudflare protection. Please check your API key and app ID.",
-			}
+		// Wait as long as the API asks for, or back off exponentially,
+		// giving up when the wait would exceed the budget or the deadline
+		now := time.Now()
+		wait, ok := retryAfter(header, now)
+		if !ok {
+			wait = c.retryPolicy.backoff(attempt)
+		}
+		if deadline, ok := budgetCtx.Deadline(); ok && now.Add(wait).After(deadline) {
+			return nil, lastErr
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			return false, &domain.APIError{
-				StatusCode: resp.StatusCode,
-				Message:    string(body),
+		timer := time.NewTimer(wait)
+		select {
+		case <-budgetCtx.Done():
+			timer.Stop()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
 			}
+			return nil, lastErr
+		case <-timer.C:
 		}
+	}
+}
 
-		var sessionDetails map[string]interface{}
-		if err := json.Unmarshal(body, &sessionDetails); err != nil {
-			return false, fmt.Errorf("failed to unmarshal response: %w", err)
-		}
-
-		// Check if session is verified
-		if status, exists := sessionDetails["status"]; exists {
-			if statusStr, ok := status.(string); ok {
-				if statusStr == "verified" {
-					// Verify phone number matches if provided
-					if userPhone != "" {
-						if phone, exists := sessionDetails["phone"]; exists {
-							if phoneStr, ok := phone.(string); ok {
-								if phoneStr != userPhone {
-									return false, nil
-								}
-							}
-						}
-					}
-					return true, nil
-				}
-			}
+// statusError returns the error reported for an unexpected response status
+func statusError(statusCode int, body []byte) error {
+	if statusCode == http.StatusForbidden {
+		return &domain.APIError{
+			StatusCode: statusCode,
+			Message:    "Access denied. This might be due to Cloudflare protection. Please check your API key and app ID.",
 		}
+	}
+	return &domain.APIError{
+		StatusCode: statusCode,
+		Message:    string(body),
+	}
+}
+
+// parseSessionDetails decodes the body of a successful /status response
+func parseSessionDetails(sessionToken string, body []byte) (*domain.SessionDetails, error) {
+	var response sessionStatusResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	details := &domain.SessionDetails{
+		Token:     response.SessionToken,
+		Status:    domain.SessionStatus(response.Status),
+		Phone:     response.Phone,
+		Channel:   response.Channel,
+		CreatedAt: response.CreatedAt.Time,
+		ExpiresAt: response.ExpiresAt.Time,
+	}
+	if details.Token == "" {
+		details.Token = sessionToken
+	}
+	return details, nil
+}
+
+// sessionStatus sends one /status request within the session status timeout
+// and returns the response status code, headers and body
+func (c *APIClient) sessionStatus(ctx context.Context, payload []byte) (int, http.Header, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.sessionStatusTimeout)
+	defer cancel()
 
-		return false, nil
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/status", bytes.NewBuffer(payload))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("X-App-ID", c.appID)
+	req.Header.Set("User-Agent", "RauthProvider-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, &domain.APIError{
+			StatusCode: 0,
+			Message:    fmt.Sprintf("failed to make request: %v", err),
+		}
 	}
+	defer resp.Body.Close()
 
-	// If we get here, all retries failed
-	return false, &domain.APIError{
-		StatusCode: 0,
-		Message:    "All retry attempts failed",
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, &domain.APIError{
+			StatusCode: 0,
+			Message:    fmt.Sprintf("failed to read response body: %v", err),
+		}
 	}
+	return resp.StatusCode, resp.Header, body, nil
 }
 
 // CheckHealth checks if the Rauth API is reachable
 func (c *APIClient) CheckHealth(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.healthCheckTimeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/health", nil)
 	if err != nil {
 		return false, fmt.Errorf("failed to create health check request: %w", err)
@@ -162,3 +333,11 @@ func (c *APIClient) CheckHealth(ctx context.Context) (bool, error) {
 
 	return resp.StatusCode == http.StatusOK, nil
 }
+
+// Close closes idle HTTP connections held by the client.
+// A client supplied through APIClientConfig.HTTPClient is left untouched.
+func (c *APIClient) Close() {
+	if c.ownsHTTPClient {
+		c.httpClient.CloseIdleConnections()
+	}
+}
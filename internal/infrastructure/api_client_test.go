@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// newTestAPIClient creates an API client sending its requests to server
+func newTestAPIClient(server *httptest.Server) *APIClient {
+	return NewAPIClient("key", "app", APIClientConfig{BaseURL: server.URL, HTTPClient: server.Client()})
+}
+
+func TestGetSessionDetails_DecodesStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"pending","phone":"+1234567890","channel":"whatsapp",` +
+			`"created_at":"2024-01-02T03:04:05Z","expires_at":1704165845}`))
+	}))
+	defer server.Close()
+
+	details, err := newTestAPIClient(server).GetSessionDetails(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("GetSessionDetails returned error: %v", err)
+	}
+
+	if details.Token != "token" {
+		t.Errorf("Expected token to default to the requested token, got %q", details.Token)
+	}
+	if details.Status != domain.SessionStatusPending {
+		t.Errorf("Expected status pending, got %q", details.Status)
+	}
+	if details.Phone != "+1234567890" || details.Channel != "whatsapp" {
+		t.Errorf("Expected phone and channel to be decoded, got %q and %q", details.Phone, details.Channel)
+	}
+	if !details.CreatedAt.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Expected created_at to be decoded from RFC 3339, got %v", details.CreatedAt)
+	}
+	if !details.ExpiresAt.Equal(time.Date(2024, 1, 2, 3, 24, 5, 0, time.UTC)) {
+		t.Errorf("Expected expires_at to be decoded from Unix seconds, got %v", details.ExpiresAt)
+	}
+}
+
+func TestGetSessionDetails_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	details, err := newTestAPIClient(server).GetSessionDetails(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("GetSessionDetails returned error: %v", err)
+	}
+	if details.Status != domain.SessionStatusNotFound {
+		t.Errorf("Expected status not_found, got %q", details.Status)
+	}
+}
+
+func TestAPIClient_AppliesPerOperationTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"status":"verified"}`))
+	}))
+	defer server.Close()
+
+	client := NewAPIClient("key", "app", APIClientConfig{
+		BaseURL:              server.URL,
+		HTTPClient:           server.Client(),
+		SessionStatusTimeout: 20 * time.Millisecond,
+		HealthCheckTimeout:   time.Second,
+		RetryPolicy:          RetryPolicy{MaxAttempts: 1},
+	})
+
+	if _, err := client.GetSessionDetails(context.Background(), "token"); err == nil {
+		t.Error("Expected the session status request to time out")
+	}
+	if healthy, err := client.CheckHealth(context.Background()); !healthy || err != nil {
+		t.Errorf("Expected the health check to use its own timeout, got %v, %v", healthy, err)
+	}
+}
@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// InProcessBroadcaster implements the domain.RevocationBroadcaster interface
+// for provider instances running in the same process
+type InProcessBroadcaster struct {
+	handlers map[int]func(*domain.RevocationMessage)
+	nextID   int
+	mutex    sync.RWMutex
+}
+
+// NewInProcessBroadcaster creates a new in-process broadcaster
+func NewInProcessBroadcaster() *InProcessBroadcaster {
+	return &InProcessBroadcaster{
+		handlers: make(map[int]func(*domain.RevocationMessage)),
+	}
+}
+
+// Publish delivers a revocation to every subscriber before returning
+func (b *InProcessBroadcaster) Publish(ctx context.Context, message *domain.RevocationMessage) error {
+	b.mutex.RLock()
+	handlers := make([]func(*domain.RevocationMessage), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mutex.RUnlock()
+
+	// Handlers run outside the lock so they may publish or unsubscribe themselves
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for published revocations
+func (b *InProcessBroadcaster) Subscribe(ctx context.Context, handler func(*domain.RevocationMessage)) (func(), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
@@ -0,0 +1,157 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState string
+
+// States of a CircuitBreaker
+const (
+	CircuitClosed   CircuitState = "closed"    // calls go through
+	CircuitOpen     CircuitState = "open"      // calls fail fast with domain.ErrCircuitOpen
+	CircuitHalfOpen CircuitState = "half_open" // one probe call goes through, the others fail fast
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failed calls that open the circuit
+	DefaultFailureThreshold = 5
+	// DefaultOpenTimeout is how long the circuit stays open before a probe call is let through
+	DefaultOpenTimeout = 30 * time.Second
+)
+
+// CircuitBreakerStats describes the state of a CircuitBreaker
+type CircuitBreakerStats struct {
+	State               CircuitState
+	ConsecutiveFailures int
+	OpenedAt            time.Time // zero unless the circuit is open or half-open
+	Rejected            int64     // calls failed fast since the breaker was created
+}
+
+// CircuitBreaker wraps a domain.APIClient and stops calling the Rauth API after
+// FailureThreshold consecutive calls found it unavailable. Once open, calls fail
+// fast with domain.ErrCircuitOpen until OpenTimeout has passed; then a single probe
+// call is let through, closing the circuit if it succeeds and opening it again if not.
+// Health checks are not guarded, they report the actual reachability of the API.
+type CircuitBreaker struct {
+	client           domain.APIClient
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	rejected int64
+}
+
+// NewCircuitBreaker creates a circuit breaker around client.
+// Non-positive settings take DefaultFailureThreshold and DefaultOpenTimeout.
+func NewCircuitBreaker(client domain.APIClient, failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = DefaultOpenTimeout
+	}
+	return &CircuitBreaker{
+		client:           client,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            CircuitClosed,
+	}
+}
+
+// GetSessionDetails returns the status of a session from the Rauth API,
+// or domain.ErrCircuitOpen without calling it while the circuit is open
+func (b *CircuitBreaker) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	probe, err := b.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := b.client.GetSessionDetails(ctx, sessionToken)
+	b.release(ctx, probe, err)
+	return details, err
+}
+
+// CheckHealth checks if the Rauth API is reachable, regardless of the circuit state
+func (b *CircuitBreaker) CheckHealth(ctx context.Context) (bool, error) {
+	return b.client.CheckHealth(ctx)
+}
+
+// acquire lets a call through or returns domain.ErrCircuitOpen, and reports
+// whether the call is the probe of a half-open circuit
+func (b *CircuitBreaker) acquire() (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.openTimeout {
+		b.state = CircuitHalfOpen
+	}
+
+	switch b.state {
+	case CircuitOpen:
+		b.rejected++
+		return false, domain.ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false, domain.ErrCircuitOpen
+		}
+		b.probing = true
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// release records the outcome of a call let through by acquire.
+// Calls abandoned by their caller count neither as a success nor as a failure.
+func (b *CircuitBreaker) release(ctx context.Context, probe bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	switch {
+	case domain.IsAPIUnavailable(err):
+		b.failures++
+		if probe || (b.state == CircuitClosed && b.failures >= b.failureThreshold) {
+			b.state = CircuitOpen
+			b.openedAt = time.Now()
+		}
+	case err != nil && ctx.Err() != nil:
+		// The caller gave up, the call says nothing about the API
+	default:
+		// The API answered
+		b.failures = 0
+		b.state = CircuitClosed
+		b.openedAt = time.Time{}
+	}
+}
+
+// Stats returns the current state of the breaker
+func (b *CircuitBreaker) Stats() CircuitBreakerStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state := b.state
+	if state == CircuitOpen && time.Since(b.openedAt) >= b.openTimeout {
+		state = CircuitHalfOpen
+	}
+	return CircuitBreakerStats{
+		State:               state,
+		ConsecutiveFailures: b.failures,
+		OpenedAt:            b.openedAt,
+		Rejected:            b.rejected,
+	}
+}
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// flakyAPIClient fails its session checks with err while err is set
+type flakyAPIClient struct {
+	err   atomic.Value // errorBox
+	calls int32
+}
+
+// errorBox lets flakyAPIClient store nil errors in an atomic.Value
+type errorBox struct{ err error }
+
+func (c *flakyAPIClient) fail(err error) {
+	c.err.Store(errorBox{err})
+}
+
+func (c *flakyAPIClient) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if box, ok := c.err.Load().(errorBox); ok && box.err != nil {
+		return nil, box.err
+	}
+	return &domain.SessionDetails{Token: sessionToken, Status: domain.SessionStatusVerified}, nil
+}
+
+func (c *flakyAPIClient) CheckHealth(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	client := &flakyAPIClient{}
+	breaker := NewCircuitBreaker(client, 2, 50*time.Millisecond)
+	ctx := context.Background()
+
+	client.fail(&domain.APIError{StatusCode: 503})
+	for i := 0; i < 2; i++ {
+		if _, err := breaker.GetSessionDetails(ctx, "token"); !domain.IsAPIUnavailable(err) || errors.Is(err, domain.ErrCircuitOpen) {
+			t.Fatalf("Expected the API error while closed, got %v", err)
+		}
+	}
+	if stats := breaker.Stats(); stats.State != CircuitOpen || stats.ConsecutiveFailures != 2 {
+		t.Fatalf("Expected the circuit to open after 2 failures, got %+v", stats)
+	}
+
+	// Open: fail fast without calling the API
+	if _, err := breaker.GetSessionDetails(ctx, "token"); !errors.Is(err, domain.ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&client.calls); calls != 2 {
+		t.Errorf("Expected no API call while open, got %d calls", calls)
+	}
+
+	// Half-open: a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if state := breaker.Stats().State; state != CircuitHalfOpen {
+		t.Errorf("Expected the circuit to be half-open after the timeout, got %s", state)
+	}
+	if _, err := breaker.GetSessionDetails(ctx, "token"); errors.Is(err, domain.ErrCircuitOpen) {
+		t.Error("Expected the probe to reach the API")
+	}
+	if _, err := breaker.GetSessionDetails(ctx, "token"); !errors.Is(err, domain.ErrCircuitOpen) {
+		t.Errorf("Expected a failed probe to open the circuit again, got %v", err)
+	}
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	client.fail(nil)
+	if _, err := breaker.GetSessionDetails(ctx, "token"); err != nil {
+		t.Errorf("Expected the probe to succeed, got %v", err)
+	}
+	if stats := breaker.Stats(); stats.State != CircuitClosed || stats.ConsecutiveFailures != 0 || stats.Rejected != 2 {
+		t.Errorf("Expected a closed circuit after 2 rejected calls, got %+v", stats)
+	}
+}
+
+func TestCircuitBreaker_SingleProbeWhenHalfOpen(t *testing.T) {
+	client := &flakyAPIClient{}
+	breaker := NewCircuitBreaker(client, 1, 10*time.Millisecond)
+	ctx := context.Background()
+
+	client.fail(&domain.APIError{StatusCode: 0, Message: "connection refused"})
+	breaker.GetSessionDetails(ctx, "token")
+	time.Sleep(20 * time.Millisecond)
+
+	probe, err := breaker.acquire()
+	if !probe || err != nil {
+		t.Fatalf("Expected the first call to be the probe, got %v, %v", probe, err)
+	}
+	if _, err := breaker.acquire(); !errors.Is(err, domain.ErrCircuitOpen) {
+		t.Errorf("Expected other calls to fail fast during the probe, got %v", err)
+	}
+
+	// An abandoned probe lets the next call probe again
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	breaker.release(canceled, true, context.Canceled)
+	if probe, err := breaker.acquire(); !probe || err != nil {
+		t.Errorf("Expected a new probe after an abandoned one, got %v, %v", probe, err)
+	}
+}
+
+func TestCircuitBreaker_IgnoresAnswers(t *testing.T) {
+	client := &flakyAPIClient{}
+	breaker := NewCircuitBreaker(client, 1, time.Minute)
+
+	// Client errors are answers from a reachable API
+	client.fail(&domain.APIError{StatusCode: 400})
+	for i := 0; i < 3; i++ {
+		breaker.GetSessionDetails(context.Background(), "token")
+	}
+	if state := breaker.Stats().State; state != CircuitClosed {
+		t.Errorf("Expected client errors to keep the circuit closed, got %s", state)
+	}
+}
@@ -0,0 +1,139 @@
+package infrastructure
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// RetryPolicy decides how failed requests to the Rauth API are retried.
+// Zero fields take the value of DefaultRetryPolicy, except Jitter: 0 disables it.
+// A policy with no field set is DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, the first one included; 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled for each further one
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts
+	MaxBackoff time.Duration
+	// Jitter randomizes each wait by up to this fraction of it, between 0 and 1;
+	// 0 waits exactly the backoff
+	Jitter float64
+	// Budget bounds the time spent on all attempts and waits together.
+	// A deadline of the request context shorter than the budget wins.
+	Budget time.Duration
+	// RetryableStatus reports whether a response status code is retried
+	// (default: DefaultRetryableStatus)
+	RetryableStatus func(statusCode int) bool
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		InitialBackoff:  500 * time.Millisecond,
+		MaxBackoff:      5 * time.Second,
+		Jitter:          0.2,
+		Budget:          30 * time.Second,
+		RetryableStatus: DefaultRetryableStatus,
+	}
+}
+
+// DefaultRetryableStatus retries Cloudflare challenges (403), request timeouts (408),
+// rate limiting (429) and server errors (5xx) other than 501 Not Implemented
+func DefaultRetryableStatus(statusCode int) bool {
+	switch {
+	case statusCode == http.StatusForbidden,
+		statusCode == http.StatusRequestTimeout,
+		statusCode == http.StatusTooManyRequests:
+		return true
+	case statusCode >= 500 && statusCode != http.StatusNotImplemented:
+		return true
+	default:
+		return false
+	}
+}
+
+// withDefaults returns the policy with its zero fields set to the default ones,
+// or DefaultRetryPolicy when no field is set
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.isZero() {
+		return defaults
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+	if p.Budget <= 0 {
+		p.Budget = defaults.Budget
+	}
+	if p.RetryableStatus == nil {
+		p.RetryableStatus = defaults.RetryableStatus
+	}
+	return p
+}
+
+// isZero reports whether no field of the policy is set
+func (p RetryPolicy) isZero() bool {
+	return p.MaxAttempts == 0 && p.InitialBackoff == 0 && p.MaxBackoff == 0 &&
+		p.Jitter == 0 && p.Budget == 0 && p.RetryableStatus == nil
+}
+
+// retryable reports whether an attempt that failed with statusCode or err is worth retrying.
+// Only transport errors are retried; they are reported as APIErrors without status code.
+func (p RetryPolicy) retryable(statusCode int, err error) bool {
+	if err != nil {
+		var apiErr *domain.APIError
+		return errors.As(err, &apiErr) && apiErr.StatusCode == 0
+	}
+	return p.RetryableStatus(statusCode)
+}
+
+// backoff returns the randomized wait before retrying after the given attempt (1-based)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	jitter := 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(backoff) * jitter)
+}
+
+// retryAfter returns the wait requested by a Retry-After header, given either
+// in seconds or as an HTTP date, and whether the header was present and valid
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
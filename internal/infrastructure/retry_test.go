@@ -0,0 +1,241 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// newRetryTestClient creates an API client with a fast retry policy sending its
+// requests to a server answering with respond, and returns the attempt counter
+func newRetryTestClient(t *testing.T, policy RetryPolicy, respond func(attempt int32, w http.ResponseWriter, r *http.Request)) (*APIClient, *int32) {
+	t.Helper()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(atomic.AddInt32(&attempts, 1), w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = time.Millisecond
+	}
+	client := NewAPIClient("key", "app", APIClientConfig{BaseURL: server.URL, HTTPClient: server.Client(), RetryPolicy: policy})
+	return client, &attempts
+}
+
+// verified answers with a verified session
+func verified(w http.ResponseWriter) {
+	w.Write([]byte(`{"status":"verified","phone":"+1234567890"}`))
+}
+
+func TestRetry_RecoversFromRetryableFailures(t *testing.T) {
+	failures := map[string]func(w http.ResponseWriter, r *http.Request){
+		"server error": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		"rate limit": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+		"cloudflare challenge": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		},
+		"dropped connection": func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		},
+	}
+
+	for name, fail := range failures {
+		client, attempts := newRetryTestClient(t, RetryPolicy{}, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+			if attempt == 1 {
+				fail(w, r)
+				return
+			}
+			verified(w)
+		})
+
+		details, err := client.GetSessionDetails(context.Background(), "token")
+		if err != nil {
+			t.Errorf("%s: GetSessionDetails returned error: %v", name, err)
+			continue
+		}
+		if details.Status != domain.SessionStatusVerified || atomic.LoadInt32(attempts) != 2 {
+			t.Errorf("%s: expected a verified session after 2 attempts, got %q after %d", name, details.Status, *attempts)
+		}
+	}
+}
+
+func TestRetry_StopsOnNonRetryableStatus(t *testing.T) {
+	client, attempts := newRetryTestClient(t, RetryPolicy{}, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+
+	_, err := client.GetSessionDetails(context.Background(), "token")
+	var apiErr *domain.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an APIError with status 400, got %v", err)
+	}
+	if *attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", *attempts)
+	}
+}
+
+func TestRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	client, attempts := newRetryTestClient(t, RetryPolicy{MaxAttempts: 4}, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := client.GetSessionDetails(context.Background(), "token")
+	var apiErr *domain.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected the last APIError with status 502, got %v", err)
+	}
+	if *attempts != 4 {
+		t.Errorf("Expected 4 attempts, got %d", *attempts)
+	}
+}
+
+func TestRetry_CustomRetryableStatus(t *testing.T) {
+	policy := RetryPolicy{RetryableStatus: func(statusCode int) bool { return statusCode == http.StatusConflict }}
+	client, attempts := newRetryTestClient(t, policy, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		if attempt == 1 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	if _, err := client.GetSessionDetails(context.Background(), "token"); err == nil {
+		t.Error("Expected an error")
+	}
+	if *attempts != 2 {
+		t.Errorf("Expected 409 to be retried and 500 not, got %d attempts", *attempts)
+	}
+}
+
+func TestRetry_RetryAfterBeyondBudgetGivesUp(t *testing.T) {
+	client, attempts := newRetryTestClient(t, RetryPolicy{Budget: 500 * time.Millisecond}, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	started := time.Now()
+	_, err := client.GetSessionDetails(context.Background(), "token")
+	var apiErr *domain.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected an APIError with status 429, got %v", err)
+	}
+	if *attempts != 1 || time.Since(started) > 400*time.Millisecond {
+		t.Errorf("Expected to give up without waiting, got %d attempts in %v", *attempts, time.Since(started))
+	}
+}
+
+func TestRetry_BudgetBoundsAttempts(t *testing.T) {
+	release := make(chan struct{})
+	client, attempts := newRetryTestClient(t, RetryPolicy{Budget: 200 * time.Millisecond}, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	// Runs before the server is closed, which waits for the handler
+	t.Cleanup(func() { close(release) })
+
+	// The attempt is cancelled when the budget runs out, whatever its own timeout
+	started := time.Now()
+	_, err := client.GetSessionDetails(context.Background(), "token")
+	var apiErr *domain.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 0 {
+		t.Errorf("Expected a transport APIError, got %v", err)
+	}
+	if *attempts != 1 || time.Since(started) > time.Second {
+		t.Errorf("Expected to give up when the budget runs out, got %d attempts in %v", *attempts, time.Since(started))
+	}
+}
+
+func TestRetry_BoundedByContext(t *testing.T) {
+	client, attempts := newRetryTestClient(t, RetryPolicy{InitialBackoff: time.Second}, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// The deadline is shorter than the backoff: no point in waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if _, err := client.GetSessionDetails(ctx, "token"); err == nil {
+		t.Error("Expected an error")
+	}
+	if *attempts != 1 || time.Since(started) > 150*time.Millisecond {
+		t.Errorf("Expected to give up before the deadline, got %d attempts in %v", *attempts, time.Since(started))
+	}
+
+	// Cancellation interrupts the backoff
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := client.GetSessionDetails(ctx, "token"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}.withDefaults()
+
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		backoff := policy.backoff(attempt)
+		if backoff < base/2 || backoff > base*3/2 {
+			t.Errorf("Expected backoff %d within 50%% of %v, got %v", attempt, base, backoff)
+		}
+	}
+}
+
+func TestRetryPolicy_ZeroJitterIsExact(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}.withDefaults()
+
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 10: time.Second} {
+		if backoff := policy.backoff(attempt); backoff != base {
+			t.Errorf("Expected backoff %d of exactly %v without jitter, got %v", attempt, base, backoff)
+		}
+	}
+
+	// An unset policy is the default one, jitter included
+	if policy := (RetryPolicy{}).withDefaults(); policy.Jitter != DefaultRetryPolicy().Jitter || policy.MaxAttempts != DefaultRetryPolicy().MaxAttempts {
+		t.Errorf("Expected the default policy, got %+v", policy)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"Tue, 02 Jan 2024 03:04:15 GMT", 10 * time.Second, true},
+		{"Tue, 02 Jan 2024 03:04:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		if test.value != "" {
+			header.Set("Retry-After", test.value)
+		}
+		wait, ok := retryAfter(header, now)
+		if wait != test.wait || ok != test.ok {
+			t.Errorf("Expected retryAfter(%q) = %v, %v, got %v, %v", test.value, test.wait, test.ok, wait, ok)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 // RevokedSessionStore implements the domain.RevokedSessionRepository interface
 type RevokedSessionStore struct {
 	revokedSessions map[string]*domain.RevokedSession
+	notBefore       map[string]time.Time
 	mutex           sync.RWMutex
 }
 
@@ -18,6 +19,7 @@ type RevokedSessionStore struct {
 func NewRevokedSessionStore() *RevokedSessionStore {
 	return &RevokedSessionStore{
 		revokedSessions: make(map[string]*domain.RevokedSession),
+		notBefore:       make(map[string]time.Time),
 	}
 }
 
@@ -54,6 +56,25 @@ func (s *RevokedSessionStore) Get(ctx context.Context, token string) (*domain.Re
 	return revokedSession, nil
 }
 
+// SetNotBefore revokes every session of scope created before notBefore
+func (s *RevokedSessionStore) SetNotBefore(ctx context.Context, scope string, notBefore time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if notBefore.After(s.notBefore[scope]) {
+		s.notBefore[scope] = notBefore
+	}
+	return nil
+}
+
+// GetNotBefore returns the epoch of scope, or the zero time when none is set
+func (s *RevokedSessionStore) GetNotBefore(ctx context.Context, scope string) (time.Time, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.notBefore[scope], nil
+}
+
 // Cleanup removes expired revoked session records
 func (s *RevokedSessionStore) Cleanup(ctx context.Context) error {
 	s.mutex.Lock()
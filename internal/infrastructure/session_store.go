@@ -2,22 +2,26 @@ package infrastructure
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/phone"
 )
 
 // SessionStore implements the domain.SessionRepository interface
 type SessionStore struct {
-	sessions map[string]*domain.Session
-	mutex    sync.RWMutex
+	sessions   map[string]*domain.Session
+	phoneIndex map[string]map[string]struct{} // E.164 phone -> tokens
+	mutex      sync.RWMutex
 }
 
 // NewSessionStore creates a new session store
 func NewSessionStore() *SessionStore {
 	return &SessionStore{
-		sessions: make(map[string]*domain.Session),
+		sessions:   make(map[string]*domain.Session),
+		phoneIndex: make(map[string]map[string]struct{}),
 	}
 }
 
@@ -26,10 +30,43 @@ func (s *SessionStore) Store(ctx context.Context, session *domain.Session) error
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	// Drop the index entry of a previous session stored under the same token
+	s.deleteLocked(session.Token)
+
 	s.sessions[session.Token] = session
+	key := phoneKey(session.UserPhone)
+	tokens, exists := s.phoneIndex[key]
+	if !exists {
+		tokens = make(map[string]struct{})
+		s.phoneIndex[key] = tokens
+	}
+	tokens[session.Token] = struct{}{}
 	return nil
 }
 
+// deleteLocked removes a session and its phone index entry.
+// The caller must hold the write lock.
+func (s *SessionStore) deleteLocked(token string) {
+	session, exists := s.sessions[token]
+	if !exists {
+		return
+	}
+
+	delete(s.sessions, token)
+	key := phoneKey(session.UserPhone)
+	if tokens, exists := s.phoneIndex[key]; exists {
+		delete(tokens, token)
+		if len(tokens) == 0 {
+			delete(s.phoneIndex, key)
+		}
+	}
+}
+
+// phoneKey returns the phone index key of a phone number, see phone.Key
+func phoneKey(number string) string {
+	return phone.Key(number)
+}
+
 // Get retrieves a session by token
 func (s *SessionStore) Get(ctx context.Context, token string) (*domain.Session, error) {
 	s.mutex.RLock()
@@ -45,7 +82,7 @@ func (s *SessionStore) Get(ctx context.Context, token string) (*domain.Session,
 		// Remove expired session
 		s.mutex.RUnlock()
 		s.mutex.Lock()
-		delete(s.sessions, token)
+		s.deleteLocked(token)
 		s.mutex.Unlock()
 		s.mutex.RLock()
 		return nil, domain.ErrSessionExpired
@@ -59,7 +96,78 @@ func (s *SessionStore) Delete(ctx context.Context, token string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	delete(s.sessions, token)
+	s.deleteLocked(token)
+	return nil
+}
+
+// DeleteByPhone removes every session of a phone number and returns their tokens
+func (s *SessionStore) DeleteByPhone(ctx context.Context, phone string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens := make([]string, 0, len(s.phoneIndex[phoneKey(phone)]))
+	for token := range s.phoneIndex[phoneKey(phone)] {
+		tokens = append(tokens, token)
+	}
+	for _, token := range tokens {
+		s.deleteLocked(token)
+	}
+
+	return tokens, nil
+}
+
+// ListSessions returns copies of the unexpired sessions of a phone number, oldest first
+func (s *SessionStore) ListSessions(ctx context.Context, phone string) ([]*domain.Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	sessions := make([]*domain.Session, 0, len(s.phoneIndex[phoneKey(phone)]))
+	for token := range s.phoneIndex[phoneKey(phone)] {
+		session := s.sessions[token]
+		if now.After(session.ExpiresAt) {
+			continue
+		}
+		sessionCopy := *session
+		sessions = append(sessions, &sessionCopy)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// CountSessions returns the number of unexpired sessions of a phone number
+func (s *SessionStore) CountSessions(ctx context.Context, phone string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for token := range s.phoneIndex[phoneKey(phone)] {
+		if !now.After(s.sessions[token].ExpiresAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Touch sets the last seen time and expiry of an unexpired session
+func (s *SessionStore) Touch(ctx context.Context, token string, lastSeenAt, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[token]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return domain.ErrSessionNotFound
+	}
+
+	// Replace rather than mutate, sessions returned by Get may still be in use
+	touched := *session
+	touched.LastSeenAt = lastSeenAt
+	touched.ExpiresAt = expiresAt
+	s.sessions[token] = &touched
 	return nil
 }
 
@@ -71,7 +179,7 @@ func (s *SessionStore) Cleanup(ctx context.Context) error {
 	now := time.Now()
 	for token, session := range s.sessions {
 		if now.After(session.ExpiresAt) {
-			delete(s.sessions, token)
+			s.deleteLocked(token)
 		}
 	}
 
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+)
+
+// CoalescingStats counts the verifications that needed the Rauth API
+type CoalescingStats struct {
+	Calls     int64 // verifications that called the Rauth API
+	Coalesced int64 // verifications that shared the result of a call already in flight
+}
+
+// verification is a verification with the Rauth API in flight
+type verification struct {
+	done     chan struct{}
+	verified bool
+	err      error
+	// abandoned is set when the caller running the verification gave up on it,
+	// so that its result says nothing to the callers waiting on it
+	abandoned bool
+}
+
+// verificationGroup coalesces concurrent verifications of the same token and phone,
+// so that a single call to the Rauth API serves every caller
+type verificationGroup struct {
+	mutex     sync.Mutex
+	inFlight  map[string]*verification
+	calls     int64
+	coalesced int64
+}
+
+// do runs verify unless a verification with the same key is already in flight,
+// in which case it waits for that one and returns its result.
+// A waiter whose leader gave up runs the verification again rather than failing
+// with the leader's context error; a waiter that gives up itself returns ctx.Err().
+func (g *verificationGroup) do(ctx context.Context, key string, verify func() (bool, error)) (bool, error) {
+	for {
+		g.mutex.Lock()
+		if g.inFlight == nil {
+			g.inFlight = make(map[string]*verification)
+		}
+		call, exists := g.inFlight[key]
+		if !exists {
+			call = &verification{done: make(chan struct{})}
+			g.inFlight[key] = call
+			g.calls++
+			g.mutex.Unlock()
+
+			g.run(ctx, key, call, verify)
+			return call.verified, call.err
+		}
+		g.coalesced++
+		g.mutex.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if !call.abandoned {
+			return call.verified, call.err
+		}
+	}
+}
+
+// run runs verify for call and releases its waiters, even if verify panics
+func (g *verificationGroup) run(ctx context.Context, key string, call *verification, verify func() (bool, error)) {
+	call.abandoned = true
+	defer func() {
+		g.mutex.Lock()
+		delete(g.inFlight, key)
+		g.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.verified, call.err = verify()
+	call.abandoned = call.err != nil && ctx.Err() != nil
+}
+
+// stats returns the counters of the group
+func (g *verificationGroup) stats() CoalescingStats {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return CoalescingStats{Calls: g.calls, Coalesced: g.coalesced}
+}
@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// gatedAPIClient holds every call until release is closed or the caller gives up
+type gatedAPIClient struct {
+	fakeAPIClient
+	release chan struct{}
+}
+
+func (c *gatedAPIClient) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	select {
+	case <-c.release:
+		return c.fakeAPIClient.GetSessionDetails(ctx, sessionToken)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// waitForStats waits until the coalescing counters of s reach want
+func waitForStats(t *testing.T, s *SessionService, want CoalescingStats) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.CoalescingStats() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected stats %+v, got %+v", want, s.CoalescingStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestVerifySession_CoalescesConcurrentChecks(t *testing.T) {
+	apiClient := &gatedAPIClient{
+		fakeAPIClient: fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}},
+		release:       make(chan struct{}),
+	}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verified, err := s.VerifySession(ctx, "token-1", "+1234567890")
+			if err == nil && !verified {
+				err = domain.ErrSessionNotFound
+			}
+			results <- err
+		}()
+	}
+
+	waitForStats(t, s, CoalescingStats{Calls: 1, Coalesced: callers - 1})
+	close(apiClient.release)
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		if err != nil {
+			t.Errorf("Expected every caller to be verified, got %v", err)
+		}
+	}
+	if apiClient.calls != 1 {
+		t.Errorf("Expected 1 API call, got %d", apiClient.calls)
+	}
+}
+
+func TestVerifySession_DoesNotCoalesceOtherPhones(t *testing.T) {
+	apiClient := &gatedAPIClient{
+		fakeAPIClient: fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}},
+		release:       make(chan struct{}),
+	}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	errs := make(chan error, 2)
+	for _, userPhone := range []string{"+1234567890", "+1987654321"} {
+		go func(userPhone string) {
+			_, err := s.VerifySession(ctx, "token-1", userPhone)
+			errs <- err
+		}(userPhone)
+	}
+
+	waitForStats(t, s, CoalescingStats{Calls: 2})
+	close(apiClient.release)
+
+	mismatches := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == domain.ErrInvalidPhoneNumber {
+			mismatches++
+		} else if err != nil {
+			t.Errorf("VerifySession returned error: %v", err)
+		}
+	}
+	if mismatches != 1 {
+		t.Errorf("Expected the other phone to be rejected, got %d mismatches", mismatches)
+	}
+}
+
+func TestVerifySession_WaitersOutliveTheirLeader(t *testing.T) {
+	apiClient := &gatedAPIClient{
+		fakeAPIClient: fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}},
+		release:       make(chan struct{}),
+	}
+	s := newTestService(apiClient)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := s.VerifySession(leaderCtx, "token-1", "+1234567890")
+		leaderErr <- err
+	}()
+	waitForStats(t, s, CoalescingStats{Calls: 1})
+
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	waiterErrs := make(chan error, 2)
+	for _, ctx := range []context.Context{context.Background(), waiterCtx} {
+		go func(ctx context.Context) {
+			verified, err := s.VerifySession(ctx, "token-1", "+1234567890")
+			if err == nil && !verified {
+				err = domain.ErrSessionNotFound
+			}
+			waiterErrs <- err
+		}(ctx)
+	}
+	waitForStats(t, s, CoalescingStats{Calls: 1, Coalesced: 2})
+
+	// A waiter giving up leaves the others waiting
+	cancelWaiter()
+	if err := <-waiterErrs; err != context.Canceled {
+		t.Errorf("Expected the cancelled waiter to get context.Canceled, got %v", err)
+	}
+
+	// The leader giving up hands the call over to the remaining waiter
+	cancelLeader()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Errorf("Expected the leader to get context.Canceled, got %v", err)
+	}
+	waitForStats(t, s, CoalescingStats{Calls: 2, Coalesced: 2})
+	close(apiClient.release)
+
+	if err := <-waiterErrs; err != nil {
+		t.Errorf("Expected the remaining waiter to be verified, got %v", err)
+	}
+}
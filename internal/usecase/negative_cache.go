@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultNegativeCacheSize is the number of tokens the negative cache holds when no size is configured
+const DefaultNegativeCacheSize = 10000
+
+// NegativeCacheStats describes the content and use of the negative cache
+type NegativeCacheStats struct {
+	Entries int   // tokens currently held, expired ones included until they are evicted
+	Hits    int64 // verifications answered from the negative cache
+}
+
+// negativeEntry is a token the Rauth API did not report as verified
+type negativeEntry struct {
+	token     string
+	expiresAt time.Time
+}
+
+// negativeCache remembers for a short while the tokens the Rauth API did not
+// verify, so that repeated checks of a bad token do not each call the API.
+// It holds at most maxEntries tokens, evicting the oldest ones first.
+type negativeCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // newest entries first
+	hits       int64
+}
+
+// newNegativeCache creates a negative cache holding at most maxEntries tokens
+// (DefaultNegativeCacheSize when maxEntries is not positive)
+func newNegativeCache(maxEntries int) *negativeCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultNegativeCacheSize
+	}
+	return &negativeCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// contains reports whether token is cached and not expired at now
+func (c *negativeCache) contains(token string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[token]
+	if !exists {
+		return false
+	}
+	entry := element.Value.(*negativeEntry)
+	if !now.Before(entry.expiresAt) {
+		c.removeElement(element)
+		return false
+	}
+	c.hits++
+	return true
+}
+
+// add caches token until expiresAt, evicting the oldest tokens when full
+func (c *negativeCache) add(token string, expiresAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[token]; exists {
+		c.removeElement(element)
+	}
+	c.entries[token] = c.order.PushFront(&negativeEntry{token: token, expiresAt: expiresAt})
+
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// remove forgets token
+func (c *negativeCache) remove(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[token]; exists {
+		c.removeElement(element)
+	}
+}
+
+// cleanup removes the entries expired at now
+func (c *negativeCache) cleanup(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if !now.Before(element.Value.(*negativeEntry).expiresAt) {
+			c.removeElement(element)
+		}
+		element = next
+	}
+}
+
+// removeElement removes an entry; the caller holds the mutex
+func (c *negativeCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*negativeEntry).token)
+}
+
+// stats returns the content and use of the cache
+func (c *negativeCache) stats() NegativeCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return NegativeCacheStats{Entries: c.order.Len(), Hits: c.hits}
+}
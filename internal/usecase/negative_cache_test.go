@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNegativeCache_EvictsOldestEntries(t *testing.T) {
+	cache := newNegativeCache(3)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		cache.add(fmt.Sprintf("token-%d", i), now.Add(time.Minute))
+	}
+	if stats := cache.stats(); stats.Entries != 3 {
+		t.Errorf("Expected 3 entries, got %d", stats.Entries)
+	}
+	for i := 0; i < 5; i++ {
+		if found, want := cache.contains(fmt.Sprintf("token-%d", i), now), i >= 2; found != want {
+			t.Errorf("token-%d: expected cached %v, got %v", i, want, found)
+		}
+	}
+
+	// Adding a token again refreshes it
+	cache.add("token-2", now.Add(time.Minute))
+	cache.add("token-5", now.Add(time.Minute))
+	if !cache.contains("token-2", now) || cache.contains("token-3", now) {
+		t.Error("Expected the refreshed token to outlive older ones")
+	}
+}
+
+func TestNegativeCache_ExpiresEntries(t *testing.T) {
+	cache := newNegativeCache(0)
+	now := time.Now()
+
+	cache.add("short", now.Add(time.Second))
+	cache.add("long", now.Add(time.Minute))
+	if !cache.contains("short", now) {
+		t.Error("Expected the token to be cached before it expires")
+	}
+	if cache.contains("short", now.Add(time.Second)) {
+		t.Error("Expected the token to expire")
+	}
+
+	cache.add("short", now.Add(time.Second))
+	cache.cleanup(now.Add(2 * time.Second))
+	if stats := cache.stats(); stats.Entries != 1 || stats.Hits != 1 {
+		t.Errorf("Expected 1 entry and 1 hit, got %+v", stats)
+	}
+}
@@ -2,9 +2,14 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"hash/fnv"
+	"sync"
 	"time"
 
 	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/phone"
 )
 
 // SessionService implements the domain.SessionService interface
@@ -13,6 +18,13 @@ type SessionService struct {
 	revokedSessionRepo domain.RevokedSessionRepository
 	apiClient          domain.APIClient
 	config             *domain.Config
+	instanceID         string
+	broadcaster        domain.RevocationBroadcaster
+	unsubscribe        func()
+	mutex              sync.Mutex
+	verifications      verificationGroup
+	negatives          *negativeCache
+	limitLocks         [64]sync.Mutex // serialize session limit checks, by phone
 }
 
 // NewSessionService creates a new session service
@@ -27,11 +39,61 @@ func NewSessionService(
 		revokedSessionRepo: revokedSessionRepo,
 		apiClient:          apiClient,
 		config:             config,
+		instanceID:         newInstanceID(),
+		negatives:          newNegativeCache(config.NegativeCacheSize),
 	}
 }
 
-// VerifySession verifies if a session is valid
+// newInstanceID returns a random ID that tells this instance's broadcasts apart
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Subscribe publishes local revocations through broadcaster and applies
+// revocations published by other instances
+func (s *SessionService) Subscribe(ctx context.Context, broadcaster domain.RevocationBroadcaster) error {
+	unsubscribe, err := broadcaster.Subscribe(ctx, s.handleRevocation)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.broadcaster = broadcaster
+	s.unsubscribe = unsubscribe
+	return nil
+}
+
+// Unsubscribe stops applying and publishing broadcast revocations
+func (s *SessionService) Unsubscribe() {
+	s.mutex.Lock()
+	unsubscribe := s.unsubscribe
+	s.broadcaster = nil
+	s.unsubscribe = nil
+	s.mutex.Unlock()
+
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
+
+// VerifySession verifies if a session is valid and belongs to userPhone.
+// It returns ErrInvalidPhoneNumber when userPhone is not a valid phone number
+// or the session belongs to another phone.
 func (s *SessionService) VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, error) {
+	if userPhone != "" {
+		normalized, err := normalizePhone(userPhone)
+		if err != nil {
+			return false, err
+		}
+		userPhone = normalized
+	}
+
 	// First check if session is revoked
 	isRevoked, err := s.IsSessionRevoked(ctx, sessionToken)
 	if err != nil && err != domain.ErrSessionNotFound {
@@ -42,38 +104,435 @@ func (s *SessionService) VerifySession(ctx context.Context, sessionToken, userPh
 	}
 
 	// Check local session store first
-	session, err := s.sessionRepo.Get(ctx, sessionToken)
+	policy := s.expiryPolicy(ctx)
+	now := time.Now()
+	cached, err := s.sessionRepo.Get(ctx, sessionToken)
 	if err == nil {
+		if err := s.checkEpoch(ctx, cached); err != nil {
+			return false, err
+		}
+
 		// Session found locally, verify phone number matches
-		if session.UserPhone == userPhone {
+		if !phoneMatches(cached.UserPhone, userPhone) {
+			return false, domain.ErrInvalidPhoneNumber
+		}
+
+		// Serve from the cache until the session goes idle or reaches its
+		// absolute lifetime, then verify it with the API again
+		if !s.isStale(cached, policy, now) {
+			s.touch(ctx, cached, policy, now)
 			return true, nil
 		}
-		return false, domain.ErrInvalidPhoneNumber
+	} else {
+		cached = nil
 	}
 
-	// Session not found locally, check with API
-	verified, err := s.apiClient.VerifySession(ctx, sessionToken, userPhone)
+	// The API recently reported this token as unknown or not verified
+	if s.negatives.contains(sessionToken, now) {
+		return false, nil
+	}
+
+	// Session not found locally or stale, check with API. Concurrent checks of
+	// the same token and phone share a single call.
+	return s.verifications.do(ctx, sessionToken+"\x00"+userPhone, func() (bool, error) {
+		return s.verifyWithAPI(ctx, sessionToken, userPhone, cached, policy, now)
+	})
+}
+
+// verifyWithAPI verifies a session that is not cached, or whose cached copy is
+// stale, with the Rauth API and caches it when verified
+func (s *SessionService) verifyWithAPI(
+	ctx context.Context,
+	sessionToken, userPhone string,
+	cached *domain.Session,
+	policy domain.ExpiryPolicy,
+	now time.Time,
+) (bool, error) {
+	details, err := s.apiClient.GetSessionDetails(ctx, sessionToken)
 	if err != nil {
+		// Degraded mode: while the API is unavailable, fail open for a stale
+		// session verified recently enough
+		if cached != nil && domain.IsAPIUnavailable(err) && now.Before(s.graceEnd(cached)) {
+			return true, nil
+		}
 		return false, err
 	}
 
-	if verified {
-		// Store the verified session locally
-		now := time.Now()
-		session := &domain.Session{
-			Token:     sessionToken,
-			UserPhone: userPhone,
-			CreatedAt: now,
-			ExpiresAt: now.Add(time.Duration(s.config.DefaultSessionTTL) * time.Second),
+	if details.Status != domain.SessionStatusVerified {
+		s.cacheNegative(sessionToken, details.Status, now)
+		return false, nil
+	}
+
+	// The phone reported by the API is the source of truth: it must match the
+	// caller's phone, and it is the phone the session is cached with. A session
+	// the API reports without a phone matches no caller phone.
+	apiPhone := phone.Key(details.Phone)
+	if !phoneMatches(apiPhone, userPhone) {
+		return false, domain.ErrInvalidPhoneNumber
+	}
+
+	// Store the verified session locally with its upstream creation and expiry,
+	// keeping what is known about a stale copy
+	session := &domain.Session{
+		Token:             sessionToken,
+		UserPhone:         apiPhone,
+		CreatedAt:         details.CreatedAt,
+		UpstreamExpiresAt: details.ExpiresAt,
+	}
+	if cached != nil {
+		session.SessionMetadata = cached.SessionMetadata
+		if session.CreatedAt.IsZero() {
+			session.CreatedAt = cached.CreatedAt
 		}
+	} else if metadata, ok := domain.MetadataFromContext(ctx); ok {
+		session.SessionMetadata = metadata
+	}
+
+	// Sessions the API reports without a creation time are dated from when they
+	// are first seen, so an epoch only revokes them once they have been cached
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	if err := s.checkEpoch(ctx, session); err != nil {
+		return false, err
+	}
+
+	// Without a phone to check, the session is verified but not cached
+	if apiPhone == "" {
+		return true, nil
+	}
+
+	if details.Channel != "" {
+		session.Channel = details.Channel
+	}
+	session.VerifiedAt = now
+	session.LastSeenAt = now
+	session.ExpiresAt = s.expiresAt(session, s.retentionPolicy(policy), now)
+
+	unlock := s.lockSessionLimit(session.UserPhone)
+	defer unlock()
+	if err := s.enforceSessionLimit(ctx, session); err != nil {
+		return false, err
+	}
+
+	if err := s.storeSession(ctx, session); err == domain.ErrSessionRevoked {
+		return false, err
+	} else if err != nil {
+		// Log error but don't fail the verification
+		// The session is still valid according to the API
+	}
+
+	return true, nil
+}
+
+// cacheNegative remembers a token the API did not verify, so that it is not
+// asked again before the negative TTL of its status has elapsed
+func (s *SessionService) cacheNegative(sessionToken string, status domain.SessionStatus, now time.Time) {
+	var ttl int
+	switch status {
+	case domain.SessionStatusNotFound, domain.SessionStatusExpired, domain.SessionStatusRevoked:
+		ttl = s.config.NegativeCacheTTL
+	case domain.SessionStatusPending:
+		// Pending sessions are about to be verified, ask again sooner
+		ttl = s.config.PendingCacheTTL
+	}
+	if ttl > 0 {
+		s.negatives.add(sessionToken, now.Add(time.Duration(ttl)*time.Second))
+	}
+}
+
+// ForgetUnverified removes a token from the negative cache, e.g. when Rauth
+// announces that its session was created, so that its next check asks the API
+func (s *SessionService) ForgetUnverified(sessionToken string) {
+	s.negatives.remove(sessionToken)
+}
 
-		if err := s.sessionRepo.Store(ctx, session); err != nil {
-			// Log error but don't fail the verification
-			// The session is still valid according to the API
+// NegativeCacheStats returns the content and use of the cache of unverified tokens
+func (s *SessionService) NegativeCacheStats() NegativeCacheStats {
+	return s.negatives.stats()
+}
+
+// CoalescingStats returns how many verifications called the Rauth API and how
+// many shared the result of a concurrent one
+func (s *SessionService) CoalescingStats() CoalescingStats {
+	return s.verifications.stats()
+}
+
+// phoneMatches reports whether the phone a caller presents matches the phone of
+// a session, on both the cache and the API path. An empty userPhone verifies
+// the session by its token alone.
+func phoneMatches(sessionPhone, userPhone string) bool {
+	return userPhone == "" || phone.Key(sessionPhone) == phone.Key(userPhone)
+}
+
+// normalizePhone returns userPhone in E.164 format, or ErrInvalidPhoneNumber
+// when it is not a valid phone number
+func normalizePhone(userPhone string) (string, error) {
+	normalized, err := phone.Normalize(userPhone)
+	if err != nil {
+		return "", domain.ErrInvalidPhoneNumber
+	}
+	return normalized, nil
+}
+
+// configuredPolicy returns the expiry policy set in the configuration
+func (s *SessionService) configuredPolicy() domain.ExpiryPolicy {
+	policy := domain.ExpiryPolicy{
+		IdleTimeout: time.Duration(s.config.IdleTimeout) * time.Second,
+		MaxLifetime: time.Duration(s.config.MaxSessionLifetime) * time.Second,
+	}
+	return policy
+}
+
+// expiryPolicy returns the configured expiry policy, overridden by a policy
+// carried by ctx (e.g. set per route by the middleware)
+func (s *SessionService) expiryPolicy(ctx context.Context) domain.ExpiryPolicy {
+	policy := s.configuredPolicy()
+	if override, ok := domain.ExpiryPolicyFromContext(ctx); ok {
+		if override.IdleTimeout > 0 {
+			policy.IdleTimeout = override.IdleTimeout
+		}
+		if override.MaxLifetime > 0 {
+			policy.MaxLifetime = override.MaxLifetime
 		}
 	}
+	return policy
+}
+
+// retentionPolicy returns the looser of policy and the configured policy, so that
+// a strict route does not evict sessions that other routes would still accept.
+// Each route applies its own policy when it reads the session.
+func (s *SessionService) retentionPolicy(policy domain.ExpiryPolicy) domain.ExpiryPolicy {
+	configured := s.configuredPolicy()
+	if configured.MaxLifetime <= 0 {
+		policy.MaxLifetime = 0
+	} else if policy.MaxLifetime > 0 && configured.MaxLifetime > policy.MaxLifetime {
+		policy.MaxLifetime = configured.MaxLifetime
+	}
+	if configured.IdleTimeout <= 0 {
+		policy.IdleTimeout = 0
+	} else if policy.IdleTimeout > 0 && configured.IdleTimeout > policy.IdleTimeout {
+		policy.IdleTimeout = configured.IdleTimeout
+	}
+	return policy
+}
+
+// lifetimeEnd returns when a session reaches its absolute lifetime under policy.
+// Without a MaxLifetime, sessions live until their upstream expiry, or for
+// DefaultSessionTTL when the API reported none. Sessions cached without
+// VerifiedAt keep the expiry they were stored with.
+func (s *SessionService) lifetimeEnd(session *domain.Session, policy domain.ExpiryPolicy) time.Time {
+	if session.VerifiedAt.IsZero() {
+		return session.ExpiresAt
+	}
+	if policy.MaxLifetime <= 0 {
+		if !session.UpstreamExpiresAt.IsZero() {
+			return session.UpstreamExpiresAt
+		}
+		return session.VerifiedAt.Add(time.Duration(s.config.DefaultSessionTTL) * time.Second)
+	}
+
+	end := session.VerifiedAt.Add(policy.MaxLifetime)
+	if !session.UpstreamExpiresAt.IsZero() && session.UpstreamExpiresAt.Before(end) {
+		end = session.UpstreamExpiresAt
+	}
+	return end
+}
+
+// isStale reports whether a cached session has been idle for too long or
+// has outlived its absolute lifetime under policy
+func (s *SessionService) isStale(session *domain.Session, policy domain.ExpiryPolicy, now time.Time) bool {
+	if now.After(s.lifetimeEnd(session, policy)) {
+		return true
+	}
+	if policy.IdleTimeout > 0 && !session.LastSeenAt.IsZero() && now.After(session.LastSeenAt.Add(policy.IdleTimeout)) {
+		return true
+	}
+	return false
+}
+
+// expiresAt returns when a session last seen at now leaves the cache:
+// after the idle timeout, but never past its absolute lifetime. In the fail-open
+// degraded mode, sessions are kept until the end of their grace period.
+func (s *SessionService) expiresAt(session *domain.Session, policy domain.ExpiryPolicy, now time.Time) time.Time {
+	expiresAt := s.lifetimeEnd(session, policy)
+	if policy.IdleTimeout > 0 {
+		if idleAt := now.Add(policy.IdleTimeout); idleAt.Before(expiresAt) {
+			expiresAt = idleAt
+		}
+	}
+	if graceEnd := s.graceEnd(session); graceEnd.After(expiresAt) {
+		expiresAt = graceEnd
+	}
+	return expiresAt
+}
 
-	return verified, nil
+// graceEnd returns until when a session can be accepted without the API in the
+// fail-open degraded mode: the grace period after its last verification, but never
+// past its upstream expiry. It is zero in the fail-closed mode.
+func (s *SessionService) graceEnd(session *domain.Session) time.Time {
+	if s.config.DegradedMode != domain.DegradedModeFailOpen || session.VerifiedAt.IsZero() {
+		return time.Time{}
+	}
+
+	end := session.VerifiedAt.Add(time.Duration(s.config.DegradedGracePeriod) * time.Second)
+	if !session.UpstreamExpiresAt.IsZero() && session.UpstreamExpiresAt.Before(end) {
+		end = session.UpstreamExpiresAt
+	}
+	return end
+}
+
+// lastSeenInterval is how often the last seen time of a session without an
+// idle timeout is written, so that a busy session is not written on every check
+const lastSeenInterval = time.Minute
+
+// touch records that a cached session was seen and slides its idle timeout.
+// Sessions without an idle timeout keep their fixed expiry, and their last
+// seen time is written at most once per lastSeenInterval.
+func (s *SessionService) touch(ctx context.Context, session *domain.Session, policy domain.ExpiryPolicy, now time.Time) {
+	expiresAt := session.ExpiresAt
+	if policy.IdleTimeout > 0 {
+		expiresAt = s.expiresAt(session, s.retentionPolicy(policy), now)
+	} else if now.Sub(session.LastSeenAt) < lastSeenInterval {
+		return
+	}
+
+	if err := s.sessionRepo.Touch(ctx, session.Token, now, expiresAt); err != nil {
+		// Log error but don't fail the verification
+		// The session was valid when it was read from the cache
+	}
+}
+
+// checkEpoch returns ErrSessionRevoked and revokes the session locally when it
+// was created before the global or per-phone revocation epoch
+func (s *SessionService) checkEpoch(ctx context.Context, session *domain.Session) error {
+	for _, scope := range []string{"", phone.Key(session.UserPhone)} {
+		notBefore, err := s.revokedSessionRepo.GetNotBefore(ctx, scope)
+		if err != nil {
+			return err
+		}
+		if !session.CreatedAt.Before(notBefore) {
+			continue
+		}
+
+		if err := s.revoke(ctx, s.newRevokedSession(session.Token, domain.Revocation{
+			Source: domain.RevocationSourceEpoch,
+			Reason: "created before the revocation epoch",
+		})); err != nil {
+			return err
+		}
+		return domain.ErrSessionRevoked
+	}
+	return nil
+}
+
+// GetSession returns the cached session of a token
+func (s *SessionService) GetSession(ctx context.Context, sessionToken string) (*domain.Session, error) {
+	session, err := s.sessionRepo.Get(ctx, sessionToken)
+	if err == domain.ErrSessionExpired {
+		return nil, domain.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+// ListSessions returns the active sessions of a phone number, oldest first.
+// It returns ErrInvalidPhoneNumber for an empty or invalid phone number.
+func (s *SessionService) ListSessions(ctx context.Context, userPhone string) ([]*domain.Session, error) {
+	userPhone, err := normalizePhone(userPhone)
+	if err != nil {
+		return nil, err
+	}
+	return s.sessionRepo.ListSessions(ctx, userPhone)
+}
+
+// CountSessions returns the number of active sessions of a phone number.
+// It returns ErrInvalidPhoneNumber for an empty or invalid phone number.
+func (s *SessionService) CountSessions(ctx context.Context, userPhone string) (int, error) {
+	userPhone, err := normalizePhone(userPhone)
+	if err != nil {
+		return 0, err
+	}
+	return s.sessionRepo.CountSessions(ctx, userPhone)
+}
+
+// lockSessionLimit serializes the session limit check and the store of new
+// sessions of a phone on this instance, so that concurrent first verifications
+// cannot all pass the check; it returns the function that releases the lock.
+// Instances sharing the session store are not serialized with each other.
+func (s *SessionService) lockSessionLimit(userPhone string) func() {
+	if s.config.MaxSessionsPerPhone <= 0 {
+		return func() {}
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(userPhone))
+	lock := &s.limitLocks[hash.Sum32()%uint32(len(s.limitLocks))]
+	lock.Lock()
+	return lock.Unlock
+}
+
+// enforceSessionLimit makes room for a new session when its phone has reached
+// MaxSessionsPerPhone, either rejecting it or revoking the oldest sessions.
+// Callers hold the lock of lockSessionLimit until the session is stored.
+func (s *SessionService) enforceSessionLimit(ctx context.Context, session *domain.Session) error {
+	if s.config.MaxSessionsPerPhone <= 0 {
+		return nil
+	}
+
+	existing, err := s.sessionRepo.ListSessions(ctx, session.UserPhone)
+	if err != nil {
+		return err
+	}
+
+	others := existing[:0]
+	for _, other := range existing {
+		if other.Token != session.Token {
+			others = append(others, other)
+		}
+	}
+	excess := len(others) - s.config.MaxSessionsPerPhone + 1
+	if excess <= 0 {
+		return nil
+	}
+
+	if s.config.SessionLimitPolicy == domain.SessionLimitReject {
+		return domain.ErrSessionLimitExceeded
+	}
+
+	// Sessions are listed oldest first
+	for _, oldest := range others[:excess] {
+		if err := s.RevokeSession(ctx, oldest.Token, domain.Revocation{
+			Source: domain.RevocationSourceEviction,
+			Reason: "maximum sessions per phone exceeded",
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSessionDetails returns the status of a session from the Rauth API.
+// Sessions revoked locally are reported as revoked, the API does not know
+// about revocations made by this application.
+func (s *SessionService) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	details, err := s.apiClient.GetSessionDetails(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	isRevoked, err := s.IsSessionRevoked(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if isRevoked {
+		details.Status = domain.SessionStatusRevoked
+	}
+	return details, nil
 }
 
 // IsSessionRevoked checks if a session has been revoked
@@ -88,26 +547,388 @@ func (s *SessionService) IsSessionRevoked(ctx context.Context, sessionToken stri
 	return true, nil
 }
 
-// RevokeSession revokes a session
-func (s *SessionService) RevokeSession(ctx context.Context, sessionToken string) error {
-	// Remove from active sessions
-	if err := s.sessionRepo.Delete(ctx, sessionToken); err != nil && err != domain.ErrSessionNotFound {
+// CacheSession stores a session announced by Rauth (e.g. through a session_created
+// webhook), so that its first verification needs no API round trip. createdAt is
+// when Rauth created the session (zero for now) and ttl its upstream lifetime;
+// the session may leave the cache earlier under the expiry policy. Sessions that
+// were revoked or have already ended, e.g. because the announcement was
+// delivered late, are not cached.
+func (s *SessionService) CacheSession(ctx context.Context, sessionToken, userPhone string, createdAt time.Time, ttl time.Duration) error {
+	userPhone, err := normalizePhone(userPhone)
+	if err != nil {
 		return err
 	}
 
-	// Add to revoked sessions
+	// The session may have been checked before it was verified
+	s.ForgetUnverified(sessionToken)
+
+	// A revocation that arrived first wins
+	isRevoked, err := s.IsSessionRevoked(ctx, sessionToken)
+	if err != nil {
+		return err
+	}
+	if isRevoked {
+		return nil
+	}
+
 	now := time.Now()
-	revokedSession := &domain.RevokedSession{
+	if createdAt.IsZero() || createdAt.After(now) {
+		createdAt = now
+	}
+	session := &domain.Session{
+		Token:      sessionToken,
+		UserPhone:  userPhone,
+		CreatedAt:  createdAt,
+		VerifiedAt: now,
+	}
+	if ttl > 0 {
+		session.UpstreamExpiresAt = createdAt.Add(ttl)
+		if !session.UpstreamExpiresAt.After(now) {
+			return nil
+		}
+	}
+	session.ExpiresAt = s.expiresAt(session, s.retentionPolicy(s.configuredPolicy()), now)
+
+	if err := s.checkEpoch(ctx, session); err != nil {
+		if err == domain.ErrSessionRevoked {
+			return nil
+		}
+		return err
+	}
+
+	unlock := s.lockSessionLimit(session.UserPhone)
+	defer unlock()
+	if err := s.enforceSessionLimit(ctx, session); err != nil {
+		if err == domain.ErrSessionLimitExceeded {
+			// Leave it to the first verification to reject the session
+			return nil
+		}
+		return err
+	}
+
+	if err := s.storeSession(ctx, session); err != nil && err != domain.ErrSessionRevoked {
+		return err
+	}
+	return nil
+}
+
+// RevokeSession revokes a session, keeping the revocation for at least
+// revocation.TTL (or the default revoked TTL), and broadcasts it to other instances
+func (s *SessionService) RevokeSession(ctx context.Context, sessionToken string, revocation domain.Revocation) error {
+	_, err := s.sessionRepo.Get(ctx, sessionToken)
+	cached := err == nil
+
+	revokedSession := s.newRevokedSession(sessionToken, revocation)
+	if err := s.revoke(ctx, revokedSession); err != nil {
+		return err
+	}
+	if err := s.publishRevocation(ctx, revokedSession); err != nil {
+		return err
+	}
+
+	// The revocation is in force; now make it outlive a session that was not cached
+	if revocation.TTL > 0 || cached || !s.extendToUpstreamExpiry(ctx, revokedSession) {
+		return nil
+	}
+	if err := s.storeRevocation(ctx, revokedSession); err != nil {
+		return err
+	}
+	return s.publishRevocation(ctx, revokedSession)
+}
+
+// publishRevocation broadcasts the revocation of a single token
+func (s *SessionService) publishRevocation(ctx context.Context, revokedSession *domain.RevokedSession) error {
+	return s.publish(ctx, &domain.RevocationMessage{
+		Token:     revokedSession.Token,
+		RevokedAt: revokedSession.RevokedAt,
+		ExpiresAt: revokedSession.ExpiresAt,
+		Source:    revokedSession.Source,
+		Reason:    revokedSession.Reason,
+	})
+}
+
+// extendToUpstreamExpiry extends the revocation of a token that was not cached
+// locally until the session ends according to the Rauth API, so that the API
+// path cannot cache the token again once the default revoked TTL has elapsed.
+// It reports whether the revocation was extended. Cached tokens are extended
+// from the cache by revoke.
+func (s *SessionService) extendToUpstreamExpiry(ctx context.Context, revokedSession *domain.RevokedSession) bool {
+	details, err := s.apiClient.GetSessionDetails(ctx, revokedSession.Token)
+	if err != nil {
+		// Log error but don't fail the revocation
+		// It is kept for the default revoked TTL
+		return false
+	}
+	if !details.ExpiresAt.After(revokedSession.ExpiresAt) {
+		return false
+	}
+	revokedSession.ExpiresAt = details.ExpiresAt
+	return true
+}
+
+// newRevokedSession builds the revocation record of a token revoked now
+func (s *SessionService) newRevokedSession(sessionToken string, revocation domain.Revocation) *domain.RevokedSession {
+	ttl := revocation.TTL
+	if ttl <= 0 {
+		ttl = time.Duration(s.config.DefaultRevokedTTL) * time.Second
+	}
+
+	now := time.Now()
+	return &domain.RevokedSession{
 		Token:     sessionToken,
 		RevokedAt: now,
-		ExpiresAt: now.Add(time.Duration(s.config.DefaultRevokedTTL) * time.Second),
+		ExpiresAt: now.Add(ttl),
+		Source:    revocation.Source,
+		Reason:    revocation.Reason,
+	}
+}
+
+// GetRevocation returns the revocation of a session, including who revoked it and why
+func (s *SessionService) GetRevocation(ctx context.Context, sessionToken string) (*domain.RevokedSession, error) {
+	revokedSession, err := s.revokedSessionRepo.Get(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	revokedCopy := *revokedSession
+	return &revokedCopy, nil
+}
+
+// RevokeAllForPhone revokes every cached session of a phone number,
+// broadcasts the revocation and returns the number of sessions revoked.
+// It returns ErrInvalidPhoneNumber for an empty or invalid phone number.
+func (s *SessionService) RevokeAllForPhone(ctx context.Context, userPhone string, revocation domain.Revocation) (int, error) {
+	userPhone, err := normalizePhone(userPhone)
+	if err != nil {
+		return 0, err
+	}
+	template := s.newRevokedSession("", revocation)
+	tokens, latest, err := s.revokeAllForPhone(ctx, userPhone, nil, *template)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(tokens), s.publish(ctx, &domain.RevocationMessage{
+		Phone:     userPhone,
+		Tokens:    tokens,
+		RevokedAt: template.RevokedAt,
+		ExpiresAt: latest,
+		Source:    template.Source,
+		Reason:    template.Reason,
+	})
+}
+
+// SetRevocationEpoch revokes every session created before notBefore, for one
+// phone number or for all sessions when userPhone is empty, and broadcasts the epoch.
+// It returns ErrInvalidPhoneNumber for an invalid phone number.
+func (s *SessionService) SetRevocationEpoch(ctx context.Context, userPhone string, notBefore time.Time) error {
+	if notBefore.After(time.Now()) {
+		return &domain.ValidationError{Field: "not_before", Message: "revocation epoch cannot be in the future"}
+	}
+
+	if userPhone != "" {
+		normalized, err := normalizePhone(userPhone)
+		if err != nil {
+			return err
+		}
+		userPhone = normalized
+	}
+	if err := s.revokedSessionRepo.SetNotBefore(ctx, userPhone, notBefore); err != nil {
+		return err
+	}
+
+	return s.publish(ctx, &domain.RevocationMessage{
+		Phone:     userPhone,
+		NotBefore: notBefore,
+		RevokedAt: time.Now(),
+	})
+}
+
+// revokeAllForPhone removes every cached session of a phone number and records
+// revocations like template for them and for any extra tokens, returning the
+// revoked tokens and the expiry of the longest-lived revocation
+func (s *SessionService) revokeAllForPhone(ctx context.Context, phone string, extra []string, template domain.RevokedSession) ([]string, time.Time, error) {
+	sessions, err := s.sessionRepo.ListSessions(ctx, phone)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	latest := template.ExpiresAt
+	seen := make(map[string]struct{}, len(sessions)+len(extra))
+	tokens := make([]string, 0, len(sessions)+len(extra))
+	record := func(token string, end time.Time) error {
+		if _, exists := seen[token]; exists {
+			return nil
+		}
+		seen[token] = struct{}{}
+
+		// Revocations of cached sessions are kept at least until the sessions end
+		revokedSession := template
+		revokedSession.Token = token
+		if end.After(revokedSession.ExpiresAt) {
+			revokedSession.ExpiresAt = end
+		}
+		if err := s.storeRevocation(ctx, &revokedSession); err != nil {
+			return err
+		}
+		if revokedSession.ExpiresAt.After(latest) {
+			latest = revokedSession.ExpiresAt
+		}
+		tokens = append(tokens, token)
+		return nil
+	}
+
+	// Record the revocations before removing the sessions, see storeSession
+	for _, session := range sessions {
+		if err := record(session.Token, sessionEnd(session)); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	for _, token := range extra {
+		if err := record(token, time.Time{}); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	cached, err := s.sessionRepo.DeleteByPhone(ctx, phone)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// Sessions cached after they were listed
+	for _, token := range cached {
+		if err := record(token, time.Time{}); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	return tokens, latest, nil
+}
+
+// publish broadcasts a revocation to other instances when a broadcaster is configured
+func (s *SessionService) publish(ctx context.Context, message *domain.RevocationMessage) error {
+	s.mutex.Lock()
+	broadcaster := s.broadcaster
+	s.mutex.Unlock()
+
+	if broadcaster == nil {
+		return nil
+	}
+
+	message.Origin = s.instanceID
+	return broadcaster.Publish(ctx, message)
+}
+
+// sessionEnd returns the latest time a session may still be accepted,
+// which its revocation has to outlive
+func sessionEnd(session *domain.Session) time.Time {
+	if session.UpstreamExpiresAt.After(session.ExpiresAt) {
+		return session.UpstreamExpiresAt
+	}
+	return session.ExpiresAt
+}
+
+// revoke removes a session from the local cache and records the revocation,
+// keeping it at least until the cached session would have ended. A revocation
+// that expired first would let the next verification cache the token again.
+func (s *SessionService) revoke(ctx context.Context, revokedSession *domain.RevokedSession) error {
+	session, err := s.sessionRepo.Get(ctx, revokedSession.Token)
+	if err == nil {
+		if end := sessionEnd(session); end.After(revokedSession.ExpiresAt) {
+			revokedSession.ExpiresAt = end
+		}
+	} else if err != domain.ErrSessionNotFound && err != domain.ErrSessionExpired {
+		return err
+	}
+
+	// Add to revoked sessions before removing from active sessions, see storeSession
+	if err := s.storeRevocation(ctx, revokedSession); err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.Delete(ctx, revokedSession.Token); err != nil && err != domain.ErrSessionNotFound {
+		return err
+	}
+	return nil
+}
+
+// storeRevocation records a revocation, keeping the expiry of an earlier
+// revocation of the same token when it is later, so that revoking a token again
+// never shortens its revocation
+func (s *SessionService) storeRevocation(ctx context.Context, revokedSession *domain.RevokedSession) error {
+	existing, err := s.revokedSessionRepo.Get(ctx, revokedSession.Token)
+	if err == nil {
+		if existing.ExpiresAt.After(revokedSession.ExpiresAt) {
+			revokedSession.ExpiresAt = existing.ExpiresAt
+		}
+	} else if err != domain.ErrSessionNotFound {
+		return err
 	}
 
 	return s.revokedSessionRepo.Store(ctx, revokedSession)
 }
 
+// storeSession caches a session unless it has been revoked meanwhile, returning
+// ErrSessionRevoked in that case. Revocations are recorded before the session is
+// deleted, so checking the revoked store after writing the session catches any
+// revocation that raced with the write, on this instance or on another one
+// sharing the stores.
+func (s *SessionService) storeSession(ctx context.Context, session *domain.Session) error {
+	if err := s.sessionRepo.Store(ctx, session); err != nil {
+		return err
+	}
+
+	isRevoked, err := s.IsSessionRevoked(ctx, session.Token)
+	if err != nil {
+		return err
+	}
+	if !isRevoked {
+		return nil
+	}
+
+	if err := s.sessionRepo.Delete(ctx, session.Token); err != nil && err != domain.ErrSessionNotFound {
+		return err
+	}
+	return domain.ErrSessionRevoked
+}
+
+// handleRevocation applies a revocation broadcast by another instance
+func (s *SessionService) handleRevocation(message *domain.RevocationMessage) {
+	if message.Origin == s.instanceID {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var err error
+	if !message.NotBefore.IsZero() {
+		err = s.revokedSessionRepo.SetNotBefore(ctx, message.Phone, message.NotBefore)
+	} else if message.Phone != "" {
+		_, _, err = s.revokeAllForPhone(ctx, message.Phone, message.Tokens, domain.RevokedSession{
+			RevokedAt: message.RevokedAt,
+			ExpiresAt: message.ExpiresAt,
+			Source:    message.Source,
+			Reason:    message.Reason,
+		})
+	} else {
+		err = s.revoke(ctx, &domain.RevokedSession{
+			Token:     message.Token,
+			RevokedAt: message.RevokedAt,
+			ExpiresAt: message.ExpiresAt,
+			Source:    message.Source,
+			Reason:    message.Reason,
+		})
+	}
+	if err != nil {
+		// Log error but continue
+		// In a production environment, you might want to use a proper logger
+	}
+}
+
 // Cleanup performs cleanup of expired sessions and revoked sessions
 func (s *SessionService) Cleanup(ctx context.Context) error {
+	s.negatives.cleanup(time.Now())
+
 	// Cleanup expired sessions
 	if err := s.sessionRepo.Cleanup(ctx); err != nil {
 		return err
@@ -116,3 +937,21 @@ func (s *SessionService) Cleanup(ctx context.Context) error {
 	// Cleanup expired revoked sessions
 	return s.revokedSessionRepo.Cleanup(ctx)
 }
+
+// Close stops the broadcast subscription and flushes and releases any
+// repositories that implement domain.Closer
+func (s *SessionService) Close(ctx context.Context) error {
+	s.Unsubscribe()
+
+	var firstErr error
+	for _, repo := range []interface{}{s.sessionRepo, s.revokedSessionRepo} {
+		closer, ok := repo.(domain.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
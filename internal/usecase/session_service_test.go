@@ -0,0 +1,1094 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/internal/infrastructure"
+)
+
+// fakeAPIClient implements domain.APIClient with canned session details
+type fakeAPIClient struct {
+	verified map[string]string // token -> phone
+	details  map[string]domain.SessionDetails
+	err      error // returned by every call when set
+	calls    int
+	mutex    sync.Mutex
+}
+
+func (c *fakeAPIClient) GetSessionDetails(ctx context.Context, sessionToken string) (*domain.SessionDetails, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	if details, exists := c.details[sessionToken]; exists {
+		return &details, nil
+	}
+	if phone, exists := c.verified[sessionToken]; exists {
+		return &domain.SessionDetails{Token: sessionToken, Status: domain.SessionStatusVerified, Phone: phone}, nil
+	}
+	return &domain.SessionDetails{Token: sessionToken, Status: domain.SessionStatusNotFound}, nil
+}
+
+func (c *fakeAPIClient) CheckHealth(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+// newTestService creates a session service backed by in-memory stores
+func newTestService(apiClient domain.APIClient) *SessionService {
+	return NewSessionService(
+		infrastructure.NewSessionStore(),
+		infrastructure.NewRevokedSessionStore(),
+		apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600},
+	)
+}
+
+func TestRevokeAllForPhone_RevokesEverySession(t *testing.T) {
+	apiClient := &fakeAPIClient{verified: map[string]string{
+		"laptop": "+1234567890",
+		"phone":  "+1234567890",
+		"other":  "+1987654321",
+	}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	for token, phone := range apiClient.verified {
+		if verified, err := s.VerifySession(ctx, token, phone); !verified || err != nil {
+			t.Fatalf("VerifySession(%s) = %v, %v", token, verified, err)
+		}
+	}
+
+	count, err := s.RevokeAllForPhone(ctx, "+1234567890", domain.Revocation{})
+	if err != nil {
+		t.Fatalf("RevokeAllForPhone returned error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 revoked sessions, got %d", count)
+	}
+
+	for _, token := range []string{"laptop", "phone"} {
+		if _, err := s.VerifySession(ctx, token, "+1234567890"); err != domain.ErrSessionRevoked {
+			t.Errorf("Expected ErrSessionRevoked for %s, got %v", token, err)
+		}
+	}
+	if verified, err := s.VerifySession(ctx, "other", "+1987654321"); !verified || err != nil {
+		t.Errorf("Other phones should keep their sessions, got %v, %v", verified, err)
+	}
+}
+
+func TestRevokeAllForPhone_RejectsInvalidPhone(t *testing.T) {
+	s := newTestService(&fakeAPIClient{})
+	ctx := context.Background()
+
+	for _, number := range []string{"", "not a phone"} {
+		if _, err := s.RevokeAllForPhone(ctx, number, domain.Revocation{}); err != domain.ErrInvalidPhoneNumber {
+			t.Errorf("%q: expected ErrInvalidPhoneNumber, got %v", number, err)
+		}
+	}
+	if revoked, _ := s.IsSessionRevoked(ctx, ""); revoked {
+		t.Error("An empty phone should not revoke the empty token")
+	}
+}
+
+func TestPhoneLookups_RejectInvalidPhone(t *testing.T) {
+	s := newTestService(&fakeAPIClient{})
+	ctx := context.Background()
+
+	if _, err := s.ListSessions(ctx, "234-567-890"); err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("ListSessions: expected ErrInvalidPhoneNumber, got %v", err)
+	}
+	if _, err := s.CountSessions(ctx, "234-567-890"); err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("CountSessions: expected ErrInvalidPhoneNumber, got %v", err)
+	}
+	if err := s.SetRevocationEpoch(ctx, "234-567-890", time.Now()); err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("SetRevocationEpoch: expected ErrInvalidPhoneNumber, got %v", err)
+	}
+	if err := s.SetRevocationEpoch(ctx, "", time.Now()); err != nil {
+		t.Errorf("SetRevocationEpoch: expected a global epoch without a phone, got %v", err)
+	}
+}
+
+func TestRevokeAllForPhone_Broadcasts(t *testing.T) {
+	broadcaster := infrastructure.NewInProcessBroadcaster()
+	ctx := context.Background()
+
+	apiClient := &fakeAPIClient{verified: map[string]string{"token-1": "+1234567890", "token-2": "+1234567890"}}
+	replicaA := newTestService(apiClient)
+	replicaB := newTestService(apiClient)
+	for _, s := range []*SessionService{replicaA, replicaB} {
+		if err := s.Subscribe(ctx, broadcaster); err != nil {
+			t.Fatalf("Subscribe returned error: %v", err)
+		}
+		defer s.Close(ctx)
+	}
+
+	// Each replica has cached a different session of the same phone
+	replicaA.VerifySession(ctx, "token-1", "+1234567890")
+	replicaB.VerifySession(ctx, "token-2", "+1234567890")
+
+	if _, err := replicaA.RevokeAllForPhone(ctx, "+1234567890", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeAllForPhone returned error: %v", err)
+	}
+
+	for _, token := range []string{"token-1", "token-2"} {
+		if revoked, _ := replicaB.IsSessionRevoked(ctx, token); !revoked {
+			t.Errorf("Replica B should have revoked %s", token)
+		}
+	}
+	if revoked, _ := replicaA.IsSessionRevoked(ctx, "token-2"); revoked {
+		t.Error("Replica A never cached token-2 and should not know it yet")
+	}
+}
+
+func TestSessionStore_DeleteByPhoneKeepsIndexConsistent(t *testing.T) {
+	sessionStore := infrastructure.NewSessionStore()
+	ctx := context.Background()
+
+	now := time.Now()
+	sessionStore.Store(ctx, &domain.Session{Token: "token-1", UserPhone: "+1234567890", ExpiresAt: now.Add(time.Hour)})
+	// Re-storing a token under another phone must move it in the index
+	sessionStore.Store(ctx, &domain.Session{Token: "token-1", UserPhone: "+1987654321", ExpiresAt: now.Add(time.Hour)})
+
+	if tokens, _ := sessionStore.DeleteByPhone(ctx, "+1234567890"); len(tokens) != 0 {
+		t.Errorf("Expected no sessions for the old phone, got %v", tokens)
+	}
+	if tokens, _ := sessionStore.DeleteByPhone(ctx, "+1987654321"); len(tokens) != 1 {
+		t.Errorf("Expected 1 session for the new phone, got %v", tokens)
+	}
+}
+
+func TestSetRevocationEpoch_RevokesOlderSessions(t *testing.T) {
+	sessionStore := infrastructure.NewSessionStore()
+	revokedStore := infrastructure.NewRevokedSessionStore()
+	s := NewSessionService(sessionStore, revokedStore, &fakeAPIClient{}, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600})
+	ctx := context.Background()
+
+	now := time.Now()
+	sessionStore.Store(ctx, &domain.Session{Token: "old", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)})
+	sessionStore.Store(ctx, &domain.Session{Token: "other-phone", UserPhone: "+1987654321", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)})
+	sessionStore.Store(ctx, &domain.Session{Token: "new", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	// A per-phone epoch only affects that phone
+	if err := s.SetRevocationEpoch(ctx, "+1234567890", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("SetRevocationEpoch returned error: %v", err)
+	}
+	if _, err := s.VerifySession(ctx, "old", "+1234567890"); err != domain.ErrSessionRevoked {
+		t.Errorf("Expected ErrSessionRevoked for a session before the epoch, got %v", err)
+	}
+	if revoked, _ := s.IsSessionRevoked(ctx, "old"); !revoked {
+		t.Error("Sessions caught by the epoch should be recorded as revoked")
+	}
+	if verified, err := s.VerifySession(ctx, "other-phone", "+1987654321"); !verified || err != nil {
+		t.Errorf("Other phones should not be affected, got %v, %v", verified, err)
+	}
+	if verified, err := s.VerifySession(ctx, "new", "+1234567890"); !verified || err != nil {
+		t.Errorf("Sessions after the epoch should stay valid, got %v, %v", verified, err)
+	}
+
+	// A global epoch affects every phone
+	if err := s.SetRevocationEpoch(ctx, "", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("SetRevocationEpoch returned error: %v", err)
+	}
+	if _, err := s.VerifySession(ctx, "other-phone", "+1987654321"); err != domain.ErrSessionRevoked {
+		t.Errorf("Expected ErrSessionRevoked after a global epoch, got %v", err)
+	}
+
+	if err := s.SetRevocationEpoch(ctx, "", now.Add(time.Hour)); err == nil {
+		t.Error("Epochs in the future should be rejected")
+	}
+}
+
+func TestSetRevocationEpoch_RevokesUncachedSessions(t *testing.T) {
+	now := time.Now()
+	apiClient := &fakeAPIClient{
+		verified: map[string]string{"unknown-creation": "+1234567890"},
+		details: map[string]domain.SessionDetails{
+			"old": {Token: "old", Status: domain.SessionStatusVerified, Phone: "+1234567890", CreatedAt: now.Add(-time.Hour)},
+			"new": {Token: "new", Status: domain.SessionStatusVerified, Phone: "+1234567890", CreatedAt: now},
+		},
+	}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	if err := s.SetRevocationEpoch(ctx, "", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("SetRevocationEpoch returned error: %v", err)
+	}
+
+	if verified, err := s.VerifySession(ctx, "old", "+1234567890"); verified || err != domain.ErrSessionRevoked {
+		t.Errorf("Expected ErrSessionRevoked, got %v, %v", verified, err)
+	}
+	if revoked, _ := s.IsSessionRevoked(ctx, "old"); !revoked {
+		t.Error("Sessions caught by the epoch should be recorded as revoked")
+	}
+
+	// Sessions the API reports without a creation time are dated from when they are first seen
+	for _, token := range []string{"new", "unknown-creation"} {
+		if verified, err := s.VerifySession(ctx, token, "+1234567890"); !verified || err != nil {
+			t.Errorf("%s: sessions first seen after the epoch should stay valid, got %v, %v", token, verified, err)
+		}
+	}
+}
+
+func TestListSessions_ReturnsActiveSessionsOldestFirst(t *testing.T) {
+	sessionStore := infrastructure.NewSessionStore()
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), &fakeAPIClient{}, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600})
+	ctx := context.Background()
+
+	now := time.Now()
+	sessionStore.Store(ctx, &domain.Session{Token: "newer", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	sessionStore.Store(ctx, &domain.Session{Token: "older", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)})
+	sessionStore.Store(ctx, &domain.Session{Token: "expired", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)})
+	sessionStore.Store(ctx, &domain.Session{Token: "other", UserPhone: "+1987654321", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	sessions, err := s.ListSessions(ctx, "+1234567890")
+	if err != nil {
+		t.Fatalf("ListSessions returned error: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Token != "older" || sessions[1].Token != "newer" {
+		t.Errorf("Expected [older newer], got %+v", sessions)
+	}
+
+	if count, err := s.CountSessions(ctx, "+1234567890"); err != nil || count != 2 {
+		t.Errorf("Expected 2 sessions, got %d, %v", count, err)
+	}
+	if count, _ := s.CountSessions(ctx, "+1000000000"); count != 0 {
+		t.Errorf("Expected no sessions for an unknown phone, got %d", count)
+	}
+}
+
+func TestVerifySession_RecordsMetadataFromContext(t *testing.T) {
+	s := newTestService(&fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}})
+	ctx := domain.ContextWithMetadata(context.Background(), domain.SessionMetadata{
+		ClientIP:    "203.0.113.7",
+		UserAgent:   "Mozilla/5.0",
+		DeviceLabel: "Work laptop",
+	})
+
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+
+	session, err := s.GetSession(context.Background(), "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if session.ClientIP != "203.0.113.7" || session.UserAgent != "Mozilla/5.0" || session.DeviceLabel != "Work laptop" {
+		t.Errorf("Expected metadata from the context, got %+v", session.SessionMetadata)
+	}
+	if session.LastSeenAt.IsZero() {
+		t.Error("Expected LastSeenAt to be set")
+	}
+
+	if _, err := s.GetSession(context.Background(), "unknown"); err != domain.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestVerifySession_IdleTimeoutSlides(t *testing.T) {
+	sessionStore := infrastructure.NewSessionStore()
+	apiClient := &fakeAPIClient{verified: map[string]string{"idle": "+1234567890"}}
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, IdleTimeout: 60, MaxSessionLifetime: 3600})
+	ctx := context.Background()
+
+	now := time.Now()
+	active := &domain.Session{Token: "active", UserPhone: "+1234567890", CreatedAt: now.Add(-10 * time.Minute), VerifiedAt: now.Add(-10 * time.Minute), ExpiresAt: now.Add(30 * time.Second)}
+	active.LastSeenAt = now.Add(-30 * time.Second)
+	idle := &domain.Session{Token: "idle", UserPhone: "+1234567890", CreatedAt: now.Add(-10 * time.Minute), VerifiedAt: now.Add(-10 * time.Minute), ExpiresAt: now.Add(time.Hour)}
+	idle.LastSeenAt = now.Add(-2 * time.Minute)
+	sessionStore.Store(ctx, active)
+	sessionStore.Store(ctx, idle)
+
+	if verified, err := s.VerifySession(ctx, "active", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession(active) = %v, %v", verified, err)
+	}
+	if apiClient.calls != 0 {
+		t.Errorf("Active sessions should be served from the cache, got %d API calls", apiClient.calls)
+	}
+	touched, _ := s.GetSession(ctx, "active")
+	if !touched.LastSeenAt.After(active.LastSeenAt) || touched.ExpiresAt.Before(now.Add(59*time.Second)) {
+		t.Errorf("Expected the idle timeout to slide, got %+v", touched)
+	}
+
+	if verified, err := s.VerifySession(ctx, "idle", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession(idle) = %v, %v", verified, err)
+	}
+	if apiClient.calls != 1 {
+		t.Errorf("Idle sessions should be verified with the API again, got %d API calls", apiClient.calls)
+	}
+}
+
+func TestVerifySession_UpdatesLastSeenWithoutIdleTimeout(t *testing.T) {
+	sessionStore := infrastructure.NewSessionStore()
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), &fakeAPIClient{},
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, MaxSessionLifetime: 3600})
+	ctx := context.Background()
+
+	now := time.Now()
+	expiresAt := now.Add(30 * time.Minute)
+	lastSeen := map[string]time.Time{
+		"stale":  now.Add(-2 * time.Minute),
+		"recent": now.Add(-10 * time.Second),
+	}
+	for token, seenAt := range lastSeen {
+		session := &domain.Session{Token: token, UserPhone: "+1234567890", CreatedAt: now.Add(-time.Hour), VerifiedAt: now.Add(-10 * time.Minute), ExpiresAt: expiresAt}
+		session.LastSeenAt = seenAt
+		sessionStore.Store(ctx, session)
+		if verified, err := s.VerifySession(ctx, token, "+1234567890"); !verified || err != nil {
+			t.Fatalf("VerifySession(%s) = %v, %v", token, verified, err)
+		}
+	}
+
+	stale, _ := s.GetSession(ctx, "stale")
+	if !stale.LastSeenAt.After(lastSeen["stale"]) || !stale.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the last seen time to be updated and the expiry kept, got %+v", stale)
+	}
+
+	// Writes are throttled
+	recent, _ := s.GetSession(ctx, "recent")
+	if !recent.LastSeenAt.Equal(lastSeen["recent"]) {
+		t.Errorf("Expected a session seen within %v not to be written again, got %v", lastSeenInterval, recent.LastSeenAt)
+	}
+}
+
+func TestVerifySession_MaxLifetimeForcesReverification(t *testing.T) {
+	sessionStore := infrastructure.NewSessionStore()
+	apiClient := &fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}}
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, IdleTimeout: 60, MaxSessionLifetime: 3600})
+	ctx := context.Background()
+
+	now := time.Now()
+	created := now.Add(-2 * time.Hour)
+	session := &domain.Session{Token: "token-1", UserPhone: "+1234567890", CreatedAt: created, VerifiedAt: created, ExpiresAt: now.Add(time.Minute)}
+	session.LastSeenAt = now
+	session.DeviceLabel = "Work laptop"
+	sessionStore.Store(ctx, session)
+
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+	if apiClient.calls != 1 {
+		t.Errorf("Sessions past their max lifetime should be verified with the API again, got %d API calls", apiClient.calls)
+	}
+
+	reverified, err := s.GetSession(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if !reverified.VerifiedAt.After(created) || !reverified.CreatedAt.Equal(created) || reverified.DeviceLabel != "Work laptop" {
+		t.Errorf("Expected a re-verified session keeping its creation time and metadata, got %+v", reverified)
+	}
+
+	// A route can require a shorter lifetime than the configured one
+	routeCtx := domain.ContextWithExpiryPolicy(ctx, domain.ExpiryPolicy{MaxLifetime: time.Nanosecond})
+	if verified, err := s.VerifySession(routeCtx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+	if apiClient.calls != 2 {
+		t.Errorf("Expected the route policy to force re-verification, got %d API calls", apiClient.calls)
+	}
+	if _, err := s.VerifySession(ctx, "token-1", "+1234567890"); err != nil || apiClient.calls != 2 {
+		t.Errorf("Expected the configured policy to serve from the cache, got %v after %d API calls", err, apiClient.calls)
+	}
+}
+
+func TestVerifySession_UsesUpstreamExpiry(t *testing.T) {
+	now := time.Now()
+	created := now.Add(-5 * time.Minute)
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"token-1": {Token: "token-1", Status: domain.SessionStatusVerified, Phone: "+1234567890", Channel: "whatsapp", CreatedAt: created, ExpiresAt: now.Add(2 * time.Hour)},
+	}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+
+	session, err := s.GetSession(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if !session.ExpiresAt.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("Expected the cache to expire with the upstream session, got %v", session.ExpiresAt)
+	}
+	if !session.CreatedAt.Equal(created) || session.Channel != "whatsapp" {
+		t.Errorf("Expected the creation time and channel from the API, got %+v", session)
+	}
+
+	// A configured max lifetime still caps the upstream expiry
+	s = NewSessionService(infrastructure.NewSessionStore(), infrastructure.NewRevokedSessionStore(), apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, MaxSessionLifetime: 60})
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+	capped, _ := s.GetSession(ctx, "token-1")
+	if capped.ExpiresAt.After(time.Now().Add(time.Minute)) {
+		t.Errorf("Expected MaxSessionLifetime to cap the upstream expiry, got %v", capped.ExpiresAt)
+	}
+}
+
+func TestVerifySession_RejectsUnverifiedStatuses(t *testing.T) {
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"pending": {Status: domain.SessionStatusPending, Phone: "+1234567890"},
+		"expired": {Status: domain.SessionStatusExpired, Phone: "+1234567890"},
+		"revoked": {Status: domain.SessionStatusRevoked, Phone: "+1234567890"},
+	}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	for _, token := range []string{"pending", "expired", "revoked", "unknown"} {
+		if verified, err := s.VerifySession(ctx, token, "+1234567890"); verified || err != nil {
+			t.Errorf("VerifySession(%s) = %v, %v, expected false", token, verified, err)
+		}
+		if _, err := s.GetSession(ctx, token); err != domain.ErrSessionNotFound {
+			t.Errorf("Session %s should not be cached, got %v", token, err)
+		}
+	}
+}
+
+func TestVerifySession_CachesPhoneReportedByAPI(t *testing.T) {
+	apiClient := &fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	// An empty phone verifies the session by its token, caching the API's phone
+	if verified, err := s.VerifySession(ctx, "token-1", ""); !verified || err != nil {
+		t.Fatalf("VerifySession with an empty phone = %v, %v", verified, err)
+	}
+	session, err := s.GetSession(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if session.UserPhone != "+1234567890" {
+		t.Errorf("Expected the session to be cached with the API's phone, got %q", session.UserPhone)
+	}
+
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil || apiClient.calls != 1 {
+		t.Errorf("Expected the cached session to match its phone, got %v, %v after %d API calls", verified, err, apiClient.calls)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", ""); !verified || err != nil {
+		t.Errorf("Expected an empty phone to match the cached session, got %v, %v", verified, err)
+	}
+}
+
+func TestVerifySession_PhoneMismatch(t *testing.T) {
+	apiClient := &fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	// The API path and the cache path reject another phone the same way
+	if verified, err := s.VerifySession(ctx, "token-1", "+1987654321"); verified || err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("Expected ErrInvalidPhoneNumber from the API path, got %v, %v", verified, err)
+	}
+	if _, err := s.GetSession(ctx, "token-1"); err != domain.ErrSessionNotFound {
+		t.Errorf("Mismatched sessions should not be cached, got %v", err)
+	}
+
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", "+1987654321"); verified || err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("Expected ErrInvalidPhoneNumber from the cache path, got %v, %v", verified, err)
+	}
+}
+
+func TestVerifySession_NormalizesPhones(t *testing.T) {
+	apiClient := &fakeAPIClient{verified: map[string]string{"token-1": "+1 234-567-890"}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	if verified, err := s.VerifySession(ctx, "token-1", "+1 (234) 567-890"); !verified || err != nil {
+		t.Fatalf("VerifySession = %v, %v", verified, err)
+	}
+	session, err := s.GetSession(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if session.UserPhone != "+1234567890" {
+		t.Errorf("Expected the session to be cached in E.164 format, got %q", session.UserPhone)
+	}
+
+	if verified, err := s.VerifySession(ctx, "token-1", "001234567890"); !verified || err != nil {
+		t.Errorf("Expected formatted phones to match the cached session, got %v, %v", verified, err)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", "1234567890"); verified || err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("Expected ErrInvalidPhoneNumber for a phone without country code, got %v, %v", verified, err)
+	}
+
+	if count, err := s.CountSessions(ctx, "+1 234 567 890"); count != 1 || err != nil {
+		t.Errorf("Expected formatted phones to find the session, got %d, %v", count, err)
+	}
+	if count, err := s.RevokeAllForPhone(ctx, "+1 (234) 567-890", domain.Revocation{}); count != 1 || err != nil {
+		t.Errorf("Expected formatted phones to revoke the session, got %d, %v", count, err)
+	}
+}
+
+func TestVerifySession_SessionWithoutPhoneIsNotCached(t *testing.T) {
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"token-1": {Status: domain.SessionStatusVerified},
+	}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	// Without a phone from the API there is nothing to match the caller's phone against
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); verified || err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("Expected ErrInvalidPhoneNumber, got %v, %v", verified, err)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", ""); !verified || err != nil {
+		t.Fatalf("VerifySession without a phone = %v, %v", verified, err)
+	}
+	if _, err := s.GetSession(ctx, "token-1"); err != domain.ErrSessionNotFound {
+		t.Errorf("Sessions without a phone from the API should not be cached, got %v", err)
+	}
+}
+
+func TestVerifySession_SessionWithoutPhoneHonoursEpoch(t *testing.T) {
+	now := time.Now()
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"token-1": {Status: domain.SessionStatusVerified, CreatedAt: now.Add(-time.Hour)},
+	}}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	if err := s.SetRevocationEpoch(ctx, "", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("SetRevocationEpoch returned error: %v", err)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", ""); verified || err != domain.ErrSessionRevoked {
+		t.Errorf("Expected ErrSessionRevoked, got %v, %v", verified, err)
+	}
+}
+
+func TestVerifySession_CachesUnverifiedTokens(t *testing.T) {
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"pending": {Token: "pending", Status: domain.SessionStatusPending, Phone: "+1234567890"},
+		"expired": {Token: "expired", Status: domain.SessionStatusExpired, Phone: "+1234567890"},
+	}}
+	s := NewSessionService(infrastructure.NewSessionStore(), infrastructure.NewRevokedSessionStore(), apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, NegativeCacheTTL: 30, PendingCacheTTL: 5})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		for _, token := range []string{"unknown", "pending", "expired"} {
+			if verified, err := s.VerifySession(ctx, token, "+1234567890"); verified || err != nil {
+				t.Errorf("VerifySession(%s) = %v, %v", token, verified, err)
+			}
+		}
+	}
+	if apiClient.calls != 3 {
+		t.Errorf("Expected each token to be checked with the API once, got %d calls", apiClient.calls)
+	}
+	if stats := s.NegativeCacheStats(); stats.Entries != 3 || stats.Hits != 6 {
+		t.Errorf("Expected 3 entries and 6 hits, got %+v", stats)
+	}
+
+	// Pending tokens are asked again sooner
+	later := time.Now().Add(10 * time.Second)
+	if s.negatives.contains("pending", later) {
+		t.Error("Expected the pending token to expire first")
+	}
+	if !s.negatives.contains("unknown", later) || !s.negatives.contains("expired", later) {
+		t.Error("Expected unknown and expired tokens to stay cached")
+	}
+}
+
+func TestCacheSession_InvalidatesNegativeCache(t *testing.T) {
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"token-1": {Token: "token-1", Status: domain.SessionStatusPending, Phone: "+1234567890"},
+	}}
+	s := NewSessionService(infrastructure.NewSessionStore(), infrastructure.NewRevokedSessionStore(), apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, NegativeCacheTTL: 30, PendingCacheTTL: 30})
+	ctx := context.Background()
+
+	if verified, _ := s.VerifySession(ctx, "token-1", "+1234567890"); verified {
+		t.Fatal("Expected the pending session not to be verified")
+	}
+
+	// The session_created webhook arrives once the user completes verification
+	if err := s.CacheSession(ctx, "token-1", "+1234567890", time.Now(), 0); err != nil {
+		t.Fatalf("CacheSession returned error: %v", err)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Errorf("Expected the created session to be verified, got %v, %v", verified, err)
+	}
+	if stats := s.NegativeCacheStats(); stats.Entries != 0 {
+		t.Errorf("Expected the token to leave the negative cache, got %d entries", stats.Entries)
+	}
+}
+
+func TestVerifySession_DegradedMode(t *testing.T) {
+	config := &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, IdleTimeout: 60, DegradedGracePeriod: 3600}
+	now := time.Now()
+	recent := &domain.Session{Token: "recent", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Hour), VerifiedAt: now.Add(-10 * time.Minute)}
+	recent.LastSeenAt = now.Add(-5 * time.Minute)
+	old := &domain.Session{Token: "old", UserPhone: "+1234567890", CreatedAt: now.Add(-3 * time.Hour), VerifiedAt: now.Add(-2 * time.Hour)}
+	old.LastSeenAt = now.Add(-5 * time.Minute)
+
+	for _, mode := range []string{domain.DegradedModeFailClosed, domain.DegradedModeFailOpen} {
+		config.DegradedMode = mode
+		sessionStore := infrastructure.NewSessionStore()
+		apiClient := &fakeAPIClient{err: &domain.APIError{StatusCode: http.StatusServiceUnavailable}}
+		s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), apiClient, config)
+		ctx := context.Background()
+
+		// Both sessions went idle and need the API, which is down
+		for _, session := range []*domain.Session{recent, old} {
+			stored := *session
+			stored.ExpiresAt = now.Add(time.Hour)
+			sessionStore.Store(ctx, &stored)
+		}
+
+		verified, err := s.VerifySession(ctx, "recent", "+1234567890")
+		if mode == domain.DegradedModeFailOpen && (!verified || err != nil) {
+			t.Errorf("Expected %s to accept a session verified within the grace period, got %v, %v", mode, verified, err)
+		}
+		if mode == domain.DegradedModeFailClosed && (verified || !domain.IsAPIUnavailable(err)) {
+			t.Errorf("Expected %s to reject the session with the API error, got %v, %v", mode, verified, err)
+		}
+
+		if verified, err := s.VerifySession(ctx, "old", "+1234567890"); verified || !domain.IsAPIUnavailable(err) {
+			t.Errorf("Expected %s to reject a session verified before the grace period, got %v, %v", mode, verified, err)
+		}
+		if verified, _ := s.VerifySession(ctx, "unknown", "+1234567890"); verified {
+			t.Errorf("Expected %s to reject uncached sessions", mode)
+		}
+	}
+
+	// Answers of the API are not outages
+	config.DegradedMode = domain.DegradedModeFailOpen
+	sessionStore := infrastructure.NewSessionStore()
+	stored := *recent
+	stored.ExpiresAt = now.Add(time.Hour)
+	sessionStore.Store(context.Background(), &stored)
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(),
+		&fakeAPIClient{err: &domain.APIError{StatusCode: http.StatusBadRequest}}, config)
+	if verified, _ := s.VerifySession(context.Background(), "recent", "+1234567890"); verified {
+		t.Error("Expected client errors not to fail open")
+	}
+}
+
+func TestExpiresAt_KeepsSessionsForTheGracePeriod(t *testing.T) {
+	s := NewSessionService(infrastructure.NewSessionStore(), infrastructure.NewRevokedSessionStore(), &fakeAPIClient{},
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, IdleTimeout: 60,
+			DegradedMode: domain.DegradedModeFailOpen, DegradedGracePeriod: 600})
+	now := time.Now()
+
+	session := &domain.Session{Token: "token-1", VerifiedAt: now, UpstreamExpiresAt: now.Add(time.Hour)}
+	if expiresAt := s.expiresAt(session, s.configuredPolicy(), now); !expiresAt.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("Expected the session to be kept for the grace period, got %v", expiresAt.Sub(now))
+	}
+
+	session.UpstreamExpiresAt = now.Add(5 * time.Minute)
+	if expiresAt := s.expiresAt(session, s.configuredPolicy(), now); !expiresAt.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("Expected the grace period to end with the upstream session, got %v", expiresAt.Sub(now))
+	}
+}
+
+func TestGetSessionDetails_ReportsLocalRevocations(t *testing.T) {
+	s := newTestService(&fakeAPIClient{verified: map[string]string{"token-1": "+1234567890"}})
+	ctx := context.Background()
+
+	details, err := s.GetSessionDetails(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSessionDetails returned error: %v", err)
+	}
+	if details.Status != domain.SessionStatusVerified || details.Phone != "+1234567890" {
+		t.Errorf("Expected a verified session, got %+v", details)
+	}
+
+	s.RevokeSession(ctx, "token-1", domain.Revocation{})
+	details, err = s.GetSessionDetails(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSessionDetails returned error: %v", err)
+	}
+	if details.Status != domain.SessionStatusRevoked {
+		t.Errorf("Expected status revoked, got %q", details.Status)
+	}
+}
+
+func TestCacheSession_SkipsRevokedSessions(t *testing.T) {
+	apiClient := &fakeAPIClient{}
+	s := newTestService(apiClient)
+	ctx := context.Background()
+
+	if err := s.CacheSession(ctx, "token-1", "+1234567890", time.Time{}, time.Minute); err != nil {
+		t.Fatalf("CacheSession returned error: %v", err)
+	}
+	if verified, err := s.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil || apiClient.calls != 0 {
+		t.Errorf("Expected a cached session without API calls, got %v, %v after %d calls", verified, err, apiClient.calls)
+	}
+	if _, err := s.VerifySession(ctx, "token-1", "+1987654321"); err != domain.ErrInvalidPhoneNumber {
+		t.Errorf("Expected ErrInvalidPhoneNumber for another phone, got %v", err)
+	}
+
+	// A revocation delivered before the creation event wins
+	s.RevokeSession(ctx, "token-2", domain.Revocation{})
+	if err := s.CacheSession(ctx, "token-2", "+1234567890", time.Time{}, time.Minute); err != nil {
+		t.Fatalf("CacheSession returned error: %v", err)
+	}
+	if _, err := s.GetSession(ctx, "token-2"); err != domain.ErrSessionNotFound {
+		t.Errorf("Revoked sessions should not be cached, got %v", err)
+	}
+}
+
+func TestRevokeSession_RecordsRevocationDetails(t *testing.T) {
+	s := newTestService(&fakeAPIClient{})
+	ctx := context.Background()
+
+	if err := s.RevokeSession(ctx, "token-1", domain.Revocation{
+		Source: domain.RevocationSourceUserLogout,
+		Reason: "signed out from settings",
+		TTL:    time.Minute,
+	}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if err := s.RevokeSession(ctx, "token-2", domain.Revocation{Source: domain.RevocationSourceAdmin}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+
+	revoked, err := s.GetRevocation(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetRevocation returned error: %v", err)
+	}
+	if revoked.Source != domain.RevocationSourceUserLogout || revoked.Reason != "signed out from settings" || revoked.RevokedAt.IsZero() {
+		t.Errorf("Expected the revocation details, got %+v", revoked)
+	}
+	if remaining := time.Until(revoked.ExpiresAt); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Expected the revocation to last the given TTL, got %v", remaining)
+	}
+
+	revoked, _ = s.GetRevocation(ctx, "token-2")
+	if remaining := time.Until(revoked.ExpiresAt); remaining <= time.Minute {
+		t.Errorf("Expected the default revoked TTL without a TTL, got %v", remaining)
+	}
+
+	if _, err := s.GetRevocation(ctx, "unknown"); err != domain.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound for a session that is not revoked, got %v", err)
+	}
+}
+
+func TestRevokeSession_RevocationOutlivesSession(t *testing.T) {
+	// The API keeps accepting tokens revoked locally, e.g. by the session limit
+	apiClient := &fakeAPIClient{verified: map[string]string{
+		"token-1": "+1234567890",
+		"token-2": "+1987654321",
+	}}
+	revokedStore := infrastructure.NewRevokedSessionStore()
+	s := NewSessionService(infrastructure.NewSessionStore(), revokedStore, apiClient, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 1})
+	ctx := context.Background()
+
+	s.CacheSession(ctx, "token-1", "+1234567890", time.Time{}, 2*time.Hour)
+	s.CacheSession(ctx, "token-2", "+1987654321", time.Time{}, 2*time.Hour)
+	if err := s.RevokeSession(ctx, "token-1", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if _, err := s.RevokeAllForPhone(ctx, "+1987654321", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeAllForPhone returned error: %v", err)
+	}
+
+	// The revocations outlive DefaultRevokedTTL, the sessions are still alive upstream
+	for token, phone := range apiClient.verified {
+		revoked, err := revokedStore.Get(ctx, token)
+		if err != nil {
+			t.Fatalf("Get(%s) returned error: %v", token, err)
+		}
+		if remaining := time.Until(revoked.ExpiresAt); remaining < time.Hour {
+			t.Errorf("Expected the revocation of %s to last as long as the session, got %v", token, remaining)
+		}
+		if verified, err := s.VerifySession(ctx, token, phone); verified || err != domain.ErrSessionRevoked {
+			t.Errorf("Revoked session %s came back: %v, %v", token, verified, err)
+		}
+	}
+	if apiClient.calls != 0 {
+		t.Errorf("Revoked sessions should not reach the API, got %d calls", apiClient.calls)
+	}
+}
+
+func TestRevokeSession_UncachedRevocationOutlivesSession(t *testing.T) {
+	now := time.Now()
+	apiClient := &fakeAPIClient{details: map[string]domain.SessionDetails{
+		"token-1": {Token: "token-1", Status: domain.SessionStatusVerified, Phone: "+1234567890", ExpiresAt: now.Add(2 * time.Hour)},
+	}}
+	revokedStore := infrastructure.NewRevokedSessionStore()
+	s := NewSessionService(infrastructure.NewSessionStore(), revokedStore, apiClient, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 60})
+	ctx := context.Background()
+
+	// Without a TTL, the expiry of a token that is not cached comes from the API
+	if err := s.RevokeSession(ctx, "token-1", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	revoked, err := revokedStore.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if !revoked.ExpiresAt.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("Expected the revocation to last until the upstream expiry, got %v", revoked.ExpiresAt.Sub(now))
+	}
+
+	// An explicit TTL needs no API call
+	if err := s.RevokeSession(ctx, "token-2", domain.Revocation{TTL: time.Hour}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if apiClient.calls != 1 {
+		t.Errorf("Expected 1 API call, got %d", apiClient.calls)
+	}
+
+	// The default revoked TTL still applies when the API cannot tell
+	apiClient.err = &domain.APIError{StatusCode: http.StatusServiceUnavailable}
+	if err := s.RevokeSession(ctx, "token-3", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if revoked, _ := revokedStore.Get(ctx, "token-3"); revoked == nil || time.Until(revoked.ExpiresAt) > time.Minute {
+		t.Errorf("Expected the default revoked TTL, got %+v", revoked)
+	}
+}
+
+func TestRevokeSession_RevokesBeforeAskingTheAPI(t *testing.T) {
+	now := time.Now()
+	apiClient := &gatedAPIClient{
+		fakeAPIClient: fakeAPIClient{details: map[string]domain.SessionDetails{
+			"token-1": {Token: "token-1", Status: domain.SessionStatusVerified, Phone: "+1234567890", ExpiresAt: now.Add(2 * time.Hour)},
+		}},
+		release: make(chan struct{}),
+	}
+	revokedStore := infrastructure.NewRevokedSessionStore()
+	s := NewSessionService(infrastructure.NewSessionStore(), revokedStore, apiClient, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 60})
+	ctx := context.Background()
+
+	done := make(chan error, 1)
+	go func() { done <- s.RevokeSession(ctx, "token-1", domain.Revocation{}) }()
+
+	// The token is revoked while the API call is still pending
+	deadline := time.Now().Add(5 * time.Second)
+	for revoked, _ := s.IsSessionRevoked(ctx, "token-1"); !revoked; revoked, _ = s.IsSessionRevoked(ctx, "token-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the token to be revoked before the API answers")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(apiClient.release)
+	if err := <-done; err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if revoked, _ := revokedStore.Get(ctx, "token-1"); revoked == nil || !revoked.ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("Expected the revocation to be extended to the upstream expiry, got %+v", revoked)
+	}
+}
+
+func TestRevokeSession_KeepsTheLongerRevocation(t *testing.T) {
+	apiClient := &fakeAPIClient{}
+	revokedStore := infrastructure.NewRevokedSessionStore()
+	s := NewSessionService(infrastructure.NewSessionStore(), revokedStore, apiClient, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 60})
+	ctx := context.Background()
+
+	if err := s.RevokeSession(ctx, "token-1", domain.Revocation{Source: domain.RevocationSourceEviction, TTL: 2 * time.Hour}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+
+	// Revoking again while the API is down falls back to the default revoked TTL
+	apiClient.err = &domain.APIError{StatusCode: http.StatusServiceUnavailable}
+	if err := s.RevokeSession(ctx, "token-1", domain.Revocation{Source: domain.RevocationSourceAdmin}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+
+	revoked, err := revokedStore.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if time.Until(revoked.ExpiresAt) < time.Hour {
+		t.Errorf("Expected the earlier, longer revocation to be kept, got %v", time.Until(revoked.ExpiresAt))
+	}
+	if revoked.Source != domain.RevocationSourceAdmin {
+		t.Errorf("Expected the latest source, got %q", revoked.Source)
+	}
+}
+
+// slowListStore takes its time to return listed sessions, like a remote store
+type slowListStore struct {
+	domain.SessionRepository
+}
+
+func (s slowListStore) ListSessions(ctx context.Context, phone string) ([]*domain.Session, error) {
+	sessions, err := s.SessionRepository.ListSessions(ctx, phone)
+	time.Sleep(5 * time.Millisecond)
+	return sessions, err
+}
+
+func TestVerifySession_SessionLimitHoldsUnderConcurrency(t *testing.T) {
+	apiClient := &gatedAPIClient{
+		fakeAPIClient: fakeAPIClient{verified: map[string]string{}},
+		release:       make(chan struct{}),
+	}
+	const logins = 20
+	for i := 0; i < logins; i++ {
+		apiClient.verified[fmt.Sprintf("token-%d", i)] = "+1234567890"
+	}
+	s := NewSessionService(
+		slowListStore{infrastructure.NewSessionStore()},
+		infrastructure.NewRevokedSessionStore(),
+		apiClient,
+		&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, MaxSessionsPerPhone: 2, SessionLimitPolicy: domain.SessionLimitReject},
+	)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var accepted int32
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			if verified, _ := s.VerifySession(ctx, token, "+1234567890"); verified {
+				atomic.AddInt32(&accepted, 1)
+			}
+		}(fmt.Sprintf("token-%d", i))
+	}
+	waitForStats(t, s, CoalescingStats{Calls: logins})
+	close(apiClient.release)
+	wg.Wait()
+
+	if accepted != 2 {
+		t.Errorf("Expected 2 sessions to be accepted, got %d", accepted)
+	}
+	if count, _ := s.CountSessions(ctx, "+1234567890"); count != 2 {
+		t.Errorf("Expected 2 cached sessions, got %d", count)
+	}
+}
+
+func TestVerifySession_SessionLimitPolicies(t *testing.T) {
+	apiClient := &fakeAPIClient{verified: map[string]string{
+		"first":  "+1234567890",
+		"second": "+1234567890",
+		"third":  "+1234567890",
+	}}
+	ctx := context.Background()
+
+	newLimitedService := func(policy string) *SessionService {
+		return NewSessionService(
+			infrastructure.NewSessionStore(),
+			infrastructure.NewRevokedSessionStore(),
+			apiClient,
+			&domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600, MaxSessionsPerPhone: 2, SessionLimitPolicy: policy},
+		)
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		s := newLimitedService(domain.SessionLimitReject)
+		s.VerifySession(ctx, "first", "+1234567890")
+		s.VerifySession(ctx, "second", "+1234567890")
+
+		if _, err := s.VerifySession(ctx, "third", "+1234567890"); err != domain.ErrSessionLimitExceeded {
+			t.Errorf("Expected ErrSessionLimitExceeded, got %v", err)
+		}
+		// Existing sessions are re-verified from the cache without hitting the limit
+		if verified, err := s.VerifySession(ctx, "first", "+1234567890"); !verified || err != nil {
+			t.Errorf("Existing sessions should stay valid, got %v, %v", verified, err)
+		}
+	})
+
+	t.Run("evict_oldest", func(t *testing.T) {
+		s := newLimitedService(domain.SessionLimitEvictOldest)
+		s.VerifySession(ctx, "first", "+1234567890")
+		time.Sleep(time.Millisecond)
+		s.VerifySession(ctx, "second", "+1234567890")
+		time.Sleep(time.Millisecond)
+
+		if verified, err := s.VerifySession(ctx, "third", "+1234567890"); !verified || err != nil {
+			t.Fatalf("New session should be accepted, got %v, %v", verified, err)
+		}
+		if revocation, err := s.GetRevocation(ctx, "first"); err != nil || revocation.Source != domain.RevocationSourceEviction {
+			t.Errorf("Oldest session should be evicted into the revoked store, got %+v, %v", revocation, err)
+		}
+		if count, _ := s.CountSessions(ctx, "+1234567890"); count != 2 {
+			t.Errorf("Expected 2 sessions after eviction, got %d", count)
+		}
+	})
+}
+
+// delayedSessionStore widens the window between a session being checked and
+// stored, so that revocations land inside it
+type delayedSessionStore struct {
+	*infrastructure.SessionStore
+}
+
+func (s delayedSessionStore) Store(ctx context.Context, session *domain.Session) error {
+	time.Sleep(time.Millisecond)
+	return s.SessionStore.Store(ctx, session)
+}
+
+func TestVerifySession_ConcurrentRevocationWins(t *testing.T) {
+	apiClient := &fakeAPIClient{verified: map[string]string{}}
+	for i := 0; i < 50; i++ {
+		apiClient.verified[fmt.Sprintf("token-%d", i)] = "+1234567890"
+	}
+	sessionStore := delayedSessionStore{infrastructure.NewSessionStore()}
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), apiClient, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for token, phone := range apiClient.verified {
+		wg.Add(2)
+		go func(token, phone string) {
+			defer wg.Done()
+			s.VerifySession(ctx, token, phone)
+		}(token, phone)
+		go func(token string) {
+			defer wg.Done()
+			time.Sleep(500 * time.Microsecond)
+			if err := s.RevokeSession(ctx, token, domain.Revocation{}); err != nil {
+				t.Errorf("RevokeSession returned error: %v", err)
+			}
+		}(token)
+	}
+	wg.Wait()
+
+	for token := range apiClient.verified {
+		if _, err := sessionStore.Get(ctx, token); err != domain.ErrSessionNotFound {
+			t.Errorf("Revoked session %s stayed cached: %v", token, err)
+		}
+	}
+}
+
+func TestCacheSession_ConcurrentRevocationWins(t *testing.T) {
+	sessionStore := delayedSessionStore{infrastructure.NewSessionStore()}
+	s := NewSessionService(sessionStore, infrastructure.NewRevokedSessionStore(), &fakeAPIClient{}, &domain.Config{DefaultSessionTTL: 900, DefaultRevokedTTL: 3600})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		token := fmt.Sprintf("token-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := s.CacheSession(ctx, token, "+1234567890", time.Time{}, time.Hour); err != nil {
+				t.Errorf("CacheSession returned error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			time.Sleep(500 * time.Microsecond)
+			if err := s.RevokeSession(ctx, token, domain.Revocation{}); err != nil {
+				t.Errorf("RevokeSession returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 50; i++ {
+		token := fmt.Sprintf("token-%d", i)
+		if _, err := sessionStore.Get(ctx, token); err != domain.ErrSessionNotFound {
+			t.Errorf("Revoked session %s stayed cached: %v", token, err)
+		}
+	}
+}
@@ -4,14 +4,151 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/RAuth-IO/rauth-provider-go/pkg/phone"
 	"github.com/RAuth-IO/rauth-provider-go/pkg/rauthprovider"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
 )
 
+// Option configures the authentication middleware
+type Option func(*config)
+
+// config holds the settings applied by Option functions
+type config struct {
+	provider       rauthprovider.Provider
+	clientIPHeader string
+	trustedHops    int
+	expiryPolicy   rauthprovider.ExpiryPolicy
+}
+
+// WithProvider makes the middleware verify sessions against the given provider
+// instead of the default instance used by the package-level helpers
+func WithProvider(provider rauthprovider.Provider) Option {
+	return func(c *config) {
+		if provider != nil {
+			c.provider = provider
+		}
+	}
+}
+
+// WithClientIPHeader reads the client IP recorded on new sessions from a header
+// set by a trusted reverse proxy, such as "X-Forwarded-For" or "X-Real-IP".
+// Proxies append to the header and the client controls its first entries, so the
+// entry added by the outermost trusted proxy is used: the rightmost one by default,
+// see WithTrustedProxyHops. By default the connection's remote address is recorded.
+func WithClientIPHeader(header string) Option {
+	return func(c *config) {
+		c.clientIPHeader = header
+	}
+}
+
+// WithTrustedProxyHops sets the number of trusted reverse proxies in front of the
+// service that append to the client IP header (default: 1). The client IP is the
+// entry that many positions from the right; a header with fewer entries did not
+// come through every proxy and the connection's remote address is recorded instead.
+func WithTrustedProxyHops(hops int) Option {
+	return func(c *config) {
+		if hops > 0 {
+			c.trustedHops = hops
+		}
+	}
+}
+
+// WithIdleTimeout overrides the provider's IdleTimeout for the routes using this
+// middleware: cached sessions not checked for that long are verified with the API again
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.expiryPolicy.IdleTimeout = timeout
+	}
+}
+
+// WithMaxSessionLifetime overrides the provider's MaxSessionLifetime for the routes
+// using this middleware, e.g. to re-verify sessions more often on sensitive routes
+func WithMaxSessionLifetime(lifetime time.Duration) Option {
+	return func(c *config) {
+		c.expiryPolicy.MaxLifetime = lifetime
+	}
+}
+
+// newConfig builds the middleware configuration from the given options
+func newConfig(opts []Option) *config {
+	c := &config{
+		provider:    rauthprovider.GetProvider(),
+		trustedHops: 1,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// clientIP returns the client IP of a request
+func (c *config) clientIP(r *http.Request) string {
+	if c.clientIPHeader != "" {
+		// A header repeated by several proxies is one list
+		var entries []string
+		for _, value := range r.Header.Values(c.clientIPHeader) {
+			entries = append(entries, strings.Split(value, ",")...)
+		}
+		if len(entries) >= c.trustedHops {
+			if ip := strings.TrimSpace(entries[len(entries)-c.trustedHops]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// verificationContext returns the request context carrying the metadata recorded
+// on a session at its first verification and the expiry policy of the route
+func (c *config) verificationContext(r *http.Request) context.Context {
+	ctx := rauthprovider.ContextWithSessionMetadata(r.Context(), store.SessionMetadata{
+		ClientIP:    c.clientIP(r),
+		UserAgent:   r.UserAgent(),
+		DeviceLabel: r.Header.Get("X-Device-Label"),
+	})
+	if c.expiryPolicy != (rauthprovider.ExpiryPolicy{}) {
+		ctx = rauthprovider.ContextWithExpiryPolicy(ctx, c.expiryPolicy)
+	}
+	return ctx
+}
+
+// revokedMessage returns the error message for a revoked session,
+// explaining who revoked it when the revocation is known
+func (c *config) revokedMessage(ctx context.Context, sessionToken string) string {
+	revocation, err := c.provider.GetRevocation(ctx, sessionToken)
+	if err != nil {
+		return "Session revoked"
+	}
+
+	switch revocation.Source {
+	case rauthprovider.RevocationSourceUserLogout:
+		return "Signed out on another device"
+	case rauthprovider.RevocationSourceAdmin:
+		return "Blocked by admin"
+	case rauthprovider.RevocationSourceEviction:
+		return "Signed out because too many devices are signed in"
+	case rauthprovider.RevocationSourceEpoch:
+		return "Session expired, please sign in again"
+	default:
+		return "Session revoked"
+	}
+}
+
 // AuthMiddleware creates middleware that verifies Rauth sessions
-func AuthMiddleware() func(http.Handler) http.Handler {
+func AuthMiddleware(opts ...Option) func(http.Handler) http.Handler {
+	cfg := newConfig(opts)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract session token from Authorization header
@@ -40,8 +177,22 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
+			userPhone, err := phone.Normalize(userPhone)
+			if err != nil {
+				http.Error(w, "Invalid user phone", http.StatusBadRequest)
+				return
+			}
+
 			// Verify session
-			verified, err := rauthprovider.VerifySession(r.Context(), sessionToken, userPhone)
+			verified, err := cfg.provider.VerifySession(cfg.verificationContext(r), sessionToken, userPhone)
+			if errors.Is(err, rauthprovider.ErrSessionLimitExceeded) {
+				http.Error(w, "Too many active sessions", http.StatusForbidden)
+				return
+			}
+			if errors.Is(err, rauthprovider.ErrSessionRevoked) {
+				http.Error(w, cfg.revokedMessage(r.Context(), sessionToken), http.StatusUnauthorized)
+				return
+			}
 			if err != nil {
 				http.Error(w, "Session verification failed", http.StatusUnauthorized)
 				return
@@ -53,14 +204,14 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 			}
 
 			// Check if session is revoked
-			isRevoked, err := rauthprovider.IsSessionRevoked(r.Context(), sessionToken)
+			isRevoked, err := cfg.provider.IsSessionRevoked(r.Context(), sessionToken)
 			if err != nil {
 				http.Error(w, "Session status check failed", http.StatusInternalServerError)
 				return
 			}
 
 			if isRevoked {
-				http.Error(w, "Session revoked", http.StatusUnauthorized)
+				http.Error(w, cfg.revokedMessage(r.Context(), sessionToken), http.StatusUnauthorized)
 				return
 			}
 
@@ -76,7 +227,9 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 
 // OptionalAuthMiddleware creates middleware that optionally verifies Rauth sessions
 // If authentication fails, it continues without session info in context
-func OptionalAuthMiddleware() func(http.Handler) http.Handler {
+func OptionalAuthMiddleware(opts ...Option) func(http.Handler) http.Handler {
+	cfg := newConfig(opts)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract session token from Authorization header
@@ -108,8 +261,15 @@ func OptionalAuthMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
+			userPhone, err := phone.Normalize(userPhone)
+			if err != nil {
+				// Invalid user phone, continue without session
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Try to verify session
-			verified, err := rauthprovider.VerifySession(r.Context(), sessionToken, userPhone)
+			verified, err := cfg.provider.VerifySession(cfg.verificationContext(r), sessionToken, userPhone)
 			if err != nil || !verified {
 				// Verification failed, continue without session
 				next.ServeHTTP(w, r)
@@ -117,7 +277,7 @@ func OptionalAuthMiddleware() func(http.Handler) http.Handler {
 			}
 
 			// Check if session is revoked
-			isRevoked, err := rauthprovider.IsSessionRevoked(r.Context(), sessionToken)
+			isRevoked, err := cfg.provider.IsSessionRevoked(r.Context(), sessionToken)
 			if err != nil || isRevoked {
 				// Session revoked or error, continue without session
 				next.ServeHTTP(w, r)
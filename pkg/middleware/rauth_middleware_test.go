@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/rauthprovider"
+)
+
+// newTestProvider creates a provider backed by a local Rauth API that knows no session
+func newTestProvider(t *testing.T) *rauthprovider.RauthProvider {
+	t.Helper()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"not_found"}`))
+	}))
+	t.Cleanup(api.Close)
+
+	provider, err := rauthprovider.New(&rauthprovider.Config{
+		RauthAPIKey:   "test-api-key",
+		AppID:         "test-app-id",
+		WebhookSecret: "test-webhook-secret",
+		APIBaseURL:    api.URL + "/session",
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	t.Cleanup(func() { provider.Close(context.Background()) })
+	return provider
+}
+
+func TestAuthMiddleware_ExplainsRevocations(t *testing.T) {
+	provider := newTestProvider(t)
+	ctx := context.Background()
+
+	revocations := map[string]string{
+		rauthprovider.RevocationSourceUserLogout: "Signed out on another device",
+		rauthprovider.RevocationSourceAdmin:      "Blocked by admin",
+		rauthprovider.RevocationSourceWebhook:    "Session revoked",
+	}
+	handler := AuthMiddleware(WithProvider(provider))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Revoked sessions should not reach the handler")
+	}))
+
+	for source, message := range revocations {
+		token := "token-" + source
+		if err := provider.RevokeSession(ctx, token, rauthprovider.Revocation{Source: source}); err != nil {
+			t.Fatalf("RevokeSession returned error: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-User-Phone", "+1234567890")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusUnauthorized, source, rec.Code)
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != message {
+			t.Errorf("Expected %q for %s, got %q", message, source, body)
+		}
+	}
+}
+
+func TestAuthMiddleware_RejectsInvalidUserPhone(t *testing.T) {
+	provider := newTestProvider(t)
+
+	handler := AuthMiddleware(WithProvider(provider))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Invalid phones should not reach the handler")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer token-1")
+	req.Header.Set("X-User-Phone", "not a phone")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "Invalid user phone" {
+		t.Errorf("Expected %q, got %q", "Invalid user phone", body)
+	}
+}
+
+func TestClientIP_IgnoresEntriesSetByTheClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		headers []string
+		want    string
+	}{
+		{"remote address", nil, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"spoofed first entry", []Option{WithClientIPHeader("X-Forwarded-For")}, []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"repeated header", []Option{WithClientIPHeader("X-Forwarded-For")}, []string{"10.0.0.1", "203.0.113.7"}, "203.0.113.7"},
+		{"two trusted hops", []Option{WithClientIPHeader("X-Forwarded-For"), WithTrustedProxyHops(2)}, []string{"10.0.0.1, 203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"too few entries", []Option{WithClientIPHeader("X-Forwarded-For"), WithTrustedProxyHops(2)}, []string{"203.0.113.7"}, "192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		for _, value := range test.headers {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		if ip := newConfig(test.opts).clientIP(req); ip != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, ip)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package phone parses, validates and normalizes phone numbers to the E.164 format
+// used by Rauth, e.g. "+1 (234) 567-890" and "001234567890" both become "+1234567890".
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxDigits is the maximum number of digits of an E.164 number, country code included
+	maxDigits = 15
+	// minNationalDigits is the minimum number of digits after the country code
+	minNationalDigits = 4
+)
+
+// ErrInvalidNumber is returned for numbers that cannot be normalized to E.164
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+// Number is a parsed phone number
+type Number struct {
+	// CountryCode is the country calling code, e.g. "1" or "44"
+	CountryCode string
+	// NationalNumber is the number without its country code
+	NationalNumber string
+}
+
+// String returns the number in E.164 format
+func (n Number) String() string {
+	return "+" + n.CountryCode + n.NationalNumber
+}
+
+// Parse parses a phone number in international format. The number starts with
+// "+" or the "00" international prefix followed by the country calling code;
+// spaces, dots, dashes, slashes and parentheses between digits are ignored.
+func Parse(number string) (Number, error) {
+	value := strings.TrimSpace(number)
+	switch {
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	case strings.HasPrefix(value, "00"):
+		value = value[2:]
+	default:
+		return Number{}, invalid(number, "missing '+' and country calling code")
+	}
+
+	var digits strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '.' || r == '-' || r == '/' || r == '(' || r == ')':
+			// Formatting characters
+		default:
+			return Number{}, invalid(number, fmt.Sprintf("unexpected character %q", r))
+		}
+	}
+
+	all := digits.String()
+	if len(all) > maxDigits {
+		return Number{}, invalid(number, fmt.Sprintf("more than %d digits", maxDigits))
+	}
+
+	countryCode := ""
+	for length := 1; length <= 3 && length <= len(all); length++ {
+		if countryCodes[all[:length]] {
+			countryCode = all[:length]
+			break
+		}
+	}
+	if countryCode == "" {
+		return Number{}, invalid(number, "unknown country calling code")
+	}
+
+	national := all[len(countryCode):]
+	if len(national) < minNationalDigits {
+		return Number{}, invalid(number, "too few digits")
+	}
+
+	return Number{CountryCode: countryCode, NationalNumber: national}, nil
+}
+
+// Normalize returns number in E.164 format
+func Normalize(number string) (string, error) {
+	parsed, err := Parse(number)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// Valid reports whether number can be normalized to E.164
+func Valid(number string) bool {
+	_, err := Parse(number)
+	return err == nil
+}
+
+// Equal reports whether two numbers are the same once normalized.
+// Numbers that cannot be normalized are never equal.
+func Equal(a, b string) bool {
+	normalizedA, err := Normalize(a)
+	if err != nil {
+		return false
+	}
+	normalizedB, err := Normalize(b)
+	if err != nil {
+		return false
+	}
+	return normalizedA == normalizedB
+}
+
+// Key returns the key to index sessions of number by: its E.164 form, or number
+// unchanged when it cannot be normalized, so stores written before normalization
+// was introduced remain reachable
+func Key(number string) string {
+	if normalized, err := Normalize(number); err == nil {
+		return normalized
+	}
+	return number
+}
+
+// invalid returns an error wrapping ErrInvalidNumber
+func invalid(number, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidNumber, number, reason)
+}
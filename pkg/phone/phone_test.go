@@ -0,0 +1,94 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"+1234567890", "+1234567890"},
+		{"+1 (234) 567-890", "+1234567890"},
+		{"  +44 20 7946 0958 ", "+442079460958"},
+		{"0044 20.7946.0958", "+442079460958"},
+		{"+91/98765-43210", "+919876543210"},
+		{"+683 4002", "+6834002"},
+	}
+
+	for _, test := range tests {
+		normalized, err := Normalize(test.input)
+		if err != nil {
+			t.Errorf("Normalize(%q) returned error: %v", test.input, err)
+			continue
+		}
+		if normalized != test.expected {
+			t.Errorf("Expected Normalize(%q) to be %q, got %q", test.input, test.expected, normalized)
+		}
+	}
+}
+
+func TestNormalize_RejectsInvalidNumbers(t *testing.T) {
+	inputs := []string{
+		"",
+		"1234567890",        // no country code
+		"+",                 // no digits
+		"+1 234 CALL NOW",   // letters
+		"+1234+567890",      // misplaced plus
+		"+1234567890123456", // more than 15 digits
+		"+28 1234 5678",     // unassigned country code
+		"+44 123",           // too short
+	}
+
+	for _, input := range inputs {
+		if _, err := Normalize(input); !errors.Is(err, ErrInvalidNumber) {
+			t.Errorf("Expected ErrInvalidNumber for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestParse_SplitsCountryCode(t *testing.T) {
+	tests := []struct {
+		input       string
+		countryCode string
+		national    string
+	}{
+		{"+1 234 567 8901", "1", "2345678901"},
+		{"+7 912 345 67 89", "7", "9123456789"},
+		{"+49 30 123456", "49", "30123456"},
+		{"+353 1 234 5678", "353", "12345678"},
+	}
+
+	for _, test := range tests {
+		number, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", test.input, err)
+			continue
+		}
+		if number.CountryCode != test.countryCode || number.NationalNumber != test.national {
+			t.Errorf("Expected Parse(%q) to be %s/%s, got %s/%s",
+				test.input, test.countryCode, test.national, number.CountryCode, number.NationalNumber)
+		}
+	}
+}
+
+func TestEqualAndKey(t *testing.T) {
+	if !Equal("+1 (234) 567-890", "+1234567890") {
+		t.Error("Expected formatted and E.164 numbers to be equal")
+	}
+	if Equal("+1234567890", "+1987654321") {
+		t.Error("Expected different numbers not to be equal")
+	}
+	if Equal("invalid", "invalid") {
+		t.Error("Expected invalid numbers never to be equal")
+	}
+
+	if key := Key("+1 (234) 567-890"); key != "+1234567890" {
+		t.Errorf("Expected the E.164 key, got %q", key)
+	}
+	if key := Key("legacy-phone"); key != "legacy-phone" {
+		t.Errorf("Expected invalid numbers to be their own key, got %q", key)
+	}
+}
@@ -1,5 +1,10 @@
 package rauthprovider
 
+import (
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/internal/infrastructure"
+)
+
 // Config holds the configuration for RauthProvider
 type Config struct {
 	RauthAPIKey       string `json:"rauth_api_key"`
@@ -7,4 +12,79 @@ type Config struct {
 	WebhookSecret     string `json:"webhook_secret"`
 	DefaultSessionTTL int    `json:"default_session_ttl,omitempty"`
 	DefaultRevokedTTL int    `json:"default_revoked_ttl,omitempty"`
+
+	// MaxSessionsPerPhone caps the sessions a phone number can hold at once (0 means unlimited)
+	MaxSessionsPerPhone int `json:"max_sessions_per_phone,omitempty"`
+	// SessionLimitPolicy decides what happens when the cap is reached (default: SessionLimitEvictOldest)
+	SessionLimitPolicy string `json:"session_limit_policy,omitempty"`
+
+	// IdleTimeout (seconds) expires a cached session that has not been checked for that long.
+	// Each successful check extends it. 0 disables the idle timeout.
+	IdleTimeout int `json:"idle_timeout,omitempty"`
+	// MaxSessionLifetime (seconds) forces re-verification with the Rauth API once that
+	// much time has passed since the last verification (default: the session's expiry
+	// reported by the Rauth API, or DefaultSessionTTL when it reports none)
+	MaxSessionLifetime int `json:"max_session_lifetime,omitempty"`
+
+	// APIBaseURL points the provider at another Rauth API, e.g. a staging environment
+	// or a local stand-in for integration tests (default: DefaultAPIBaseURL)
+	APIBaseURL string `json:"api_base_url,omitempty"`
+	// APITimeout (seconds) bounds each session status request to the Rauth API (default: 30)
+	APITimeout int `json:"api_timeout,omitempty"`
+	// HealthCheckTimeout (seconds) bounds each health check of the Rauth API (default: 30)
+	HealthCheckTimeout int `json:"health_check_timeout,omitempty"`
+
+	// TLSCAFile is a PEM file of CA certificates trusted for the Rauth API instead of the system pool
+	TLSCAFile string `json:"tls_ca_file,omitempty"`
+	// TLSCertFile and TLSKeyFile are the PEM client certificate and key presented to the Rauth API
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+
+	// CircuitBreakerThreshold is the number of consecutive session checks finding the
+	// Rauth API unavailable that open the circuit breaker (default: 5)
+	CircuitBreakerThreshold int `json:"circuit_breaker_threshold,omitempty"`
+	// CircuitBreakerOpenTimeout (seconds) is how long the circuit breaker fails fast
+	// before letting a probe check through (default: 30)
+	CircuitBreakerOpenTimeout int `json:"circuit_breaker_open_timeout,omitempty"`
+
+	// DegradedMode decides how sessions needing the Rauth API are handled while it is
+	// unavailable (default: DegradedModeFailClosed)
+	DegradedMode string `json:"degraded_mode,omitempty"`
+	// DegradedGracePeriod (seconds) is how recently a cached session must have been
+	// verified to be accepted in DegradedModeFailOpen (default: 900)
+	DegradedGracePeriod int `json:"degraded_grace_period,omitempty"`
+
+	// NegativeCacheTTL (seconds) is how long a token the Rauth API reported as unknown,
+	// expired or revoked is rejected without asking the API again (default: 30)
+	NegativeCacheTTL int `json:"negative_cache_ttl,omitempty"`
+	// PendingCacheTTL (seconds) is how long a token the Rauth API reported as not yet
+	// verified is rejected without asking the API again (default: 5)
+	PendingCacheTTL int `json:"pending_cache_ttl,omitempty"`
+	// NegativeCacheSize bounds the number of tokens in the negative cache, the oldest
+	// ones being evicted first (default: 10000)
+	NegativeCacheSize int `json:"negative_cache_size,omitempty"`
 }
+
+// DefaultAPIBaseURL is the base URL of the production Rauth session API
+const DefaultAPIBaseURL = infrastructure.DefaultAPIBaseURL
+
+// ExpiryPolicy overrides IdleTimeout and MaxSessionLifetime for the sessions checked
+// with a context, see ContextWithExpiryPolicy. Zero fields keep the configured values.
+type ExpiryPolicy = domain.ExpiryPolicy
+
+// Degraded modes for Config.DegradedMode
+const (
+	// DegradedModeFailClosed rejects sessions that need the Rauth API while it is unavailable
+	DegradedModeFailClosed = domain.DegradedModeFailClosed
+	// DegradedModeFailOpen accepts cached sessions verified within DegradedGracePeriod
+	// while the Rauth API is unavailable
+	DegradedModeFailOpen = domain.DegradedModeFailOpen
+)
+
+// Session limit policies for Config.SessionLimitPolicy
+const (
+	// SessionLimitReject refuses new sessions once a phone reaches MaxSessionsPerPhone
+	SessionLimitReject = domain.SessionLimitReject
+	// SessionLimitEvictOldest revokes the oldest sessions of a phone to make room for a new one
+	SessionLimitEvictOldest = domain.SessionLimitEvictOldest
+)
@@ -0,0 +1,28 @@
+package rauthprovider
+
+import (
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// Errors returned by the provider, for use with errors.Is
+var (
+	ErrNotInitialized       = domain.ErrNotInitialized
+	ErrInvalidConfig        = domain.ErrInvalidConfig
+	ErrSessionNotFound      = domain.ErrSessionNotFound
+	ErrSessionExpired       = domain.ErrSessionExpired
+	ErrSessionRevoked       = domain.ErrSessionRevoked
+	ErrInvalidSignature     = domain.ErrInvalidSignature
+	ErrAPIUnreachable       = domain.ErrAPIUnreachable
+	ErrInvalidPhoneNumber   = domain.ErrInvalidPhoneNumber
+	ErrSessionLimitExceeded = domain.ErrSessionLimitExceeded
+	ErrCircuitOpen          = domain.ErrCircuitOpen
+)
+
+// ConfigError represents configuration-related errors
+type ConfigError = domain.ConfigError
+
+// APIError represents API-related errors
+type APIError = domain.APIError
+
+// ValidationError represents validation errors
+type ValidationError = domain.ValidationError
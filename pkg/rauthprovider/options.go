@@ -0,0 +1,130 @@
+package rauthprovider
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/infrastructure"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+// RetryPolicy decides how failed session checks against the Rauth API are retried,
+// see WithRetryPolicy. Zero fields take the value of DefaultRetryPolicy, except
+// Jitter: 0 disables it.
+type RetryPolicy = infrastructure.RetryPolicy
+
+// DefaultRetryPolicy returns the retry policy used when none is configured:
+// 3 attempts, exponential backoff from 500ms up to 5s with 20% jitter, within 30s
+func DefaultRetryPolicy() RetryPolicy {
+	return infrastructure.DefaultRetryPolicy()
+}
+
+// DefaultRetryableStatus retries Cloudflare challenges (403), request timeouts (408),
+// rate limiting (429) and server errors (5xx) other than 501 Not Implemented
+func DefaultRetryableStatus(statusCode int) bool {
+	return infrastructure.DefaultRetryableStatus(statusCode)
+}
+
+// Option configures optional behaviour of a RauthProvider
+type Option func(*options)
+
+// options holds the optional settings applied by Option functions
+type options struct {
+	cleanupInterval time.Duration
+	sessionStore    store.SessionRepository
+	revokedStore    store.RevokedSessionRepository
+	revocationFile  string
+	broadcaster     store.RevocationBroadcaster
+	httpClient      *http.Client
+	transport       http.RoundTripper
+	tlsConfig       *tls.Config
+	retryPolicy     RetryPolicy
+}
+
+// defaultOptions returns the options used when none are supplied
+func defaultOptions() options {
+	return options{
+		cleanupInterval: 5 * time.Minute,
+	}
+}
+
+// WithCleanupInterval sets how often expired sessions and revoked sessions are purged
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.cleanupInterval = interval
+		}
+	}
+}
+
+// WithSessionStore replaces the in-memory session store with a custom backend.
+// The provider closes the store on Close if it implements store.Closer.
+func WithSessionStore(sessionStore store.SessionRepository) Option {
+	return func(o *options) {
+		o.sessionStore = sessionStore
+	}
+}
+
+// WithRevokedStore replaces the in-memory revoked session store with a custom backend.
+// The provider closes the store on Close if it implements store.Closer.
+func WithRevokedStore(revokedStore store.RevokedSessionRepository) Option {
+	return func(o *options) {
+		o.revokedStore = revokedStore
+	}
+}
+
+// WithRevocationFile persists revocations in an append-only log at path,
+// which is loaded on Init so revocations survive restarts.
+// It cannot be combined with WithRevokedStore.
+func WithRevocationFile(path string) Option {
+	return func(o *options) {
+		o.revocationFile = path
+	}
+}
+
+// WithRevocationBroadcaster publishes revocations through broadcaster and
+// applies revocations published by other instances subscribed to it
+func WithRevocationBroadcaster(broadcaster store.RevocationBroadcaster) Option {
+	return func(o *options) {
+		o.broadcaster = broadcaster
+	}
+}
+
+// WithHTTPClient sends the requests to the Rauth API through client, e.g. one
+// shared with the rest of the application. Config.APITimeout and
+// Config.HealthCheckTimeout still apply on top of the client's own Timeout.
+// It cannot be combined with WithTransport, WithTLSConfig or the TLS files of Config.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithTransport sends the requests to the Rauth API through transport instead of
+// a clone of http.DefaultTransport, e.g. to go through a corporate egress proxy
+// or to instrument the requests. It cannot be combined with WithTLSConfig or the TLS files of Config.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to reach the Rauth API.
+// The TLS files of Config are added to a copy of it.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithRetryPolicy sets how failed session checks against the Rauth API are retried.
+// Transport errors and the statuses accepted by RetryableStatus are retried with
+// exponential backoff, honouring Retry-After, until MaxAttempts, the Budget or the
+// deadline of the request context is reached. Set MaxAttempts to 1 to disable retries.
+// Jitter is not defaulted, so start from DefaultRetryPolicy to keep it.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
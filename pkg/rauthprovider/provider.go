@@ -3,6 +3,7 @@ package rauthprovider
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
 	"github.com/RAuth-IO/rauth-provider-go/internal/infrastructure"
 	"github.com/RAuth-IO/rauth-provider-go/internal/usecase"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store/filestore"
 )
 
 // RauthProvider is the main provider for Rauth authentication
@@ -17,7 +20,12 @@ type RauthProvider struct {
 	config         *Config
 	sessionService *usecase.SessionService
 	apiClient      *infrastructure.APIClient
+	circuitBreaker *infrastructure.CircuitBreaker
 	webhookHandler *delivery.WebhookHandler
+	options        options
+	revocationLog  *filestore.RevokedSessionStore
+	stopCleanup    chan struct{}
+	cleanupDone    chan struct{}
 	initialized    bool
 	mutex          sync.RWMutex
 }
@@ -27,7 +35,7 @@ var (
 	once     sync.Once
 )
 
-// GetInstance returns the singleton instance of RauthProvider
+// GetInstance returns the default instance of RauthProvider used by the package-level helpers
 func GetInstance() *RauthProvider {
 	once.Do(func() {
 		instance = &RauthProvider{}
@@ -35,8 +43,18 @@ func GetInstance() *RauthProvider {
 	return instance
 }
 
+// New creates an independent RauthProvider with its own session stores,
+// API client, webhook handler and cleanup routine
+func New(config *Config, opts ...Option) (*RauthProvider, error) {
+	p := &RauthProvider{}
+	if err := p.Init(config, opts...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // Init initializes the RauthProvider with configuration
-func (p *RauthProvider) Init(config *Config) error {
+func (p *RauthProvider) Init(config *Config, opts ...Option) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -45,6 +63,12 @@ func (p *RauthProvider) Init(config *Config) error {
 		return err
 	}
 
+	// Apply options on top of the defaults
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	// Set default values if not provided
 	if config.DefaultSessionTTL == 0 {
 		config.DefaultSessionTTL = 900 // 15 minutes
@@ -52,40 +76,186 @@ func (p *RauthProvider) Init(config *Config) error {
 	if config.DefaultRevokedTTL == 0 {
 		config.DefaultRevokedTTL = 3600 // 1 hour
 	}
+	if config.SessionLimitPolicy == "" {
+		config.SessionLimitPolicy = SessionLimitEvictOldest
+	}
+	if config.APIBaseURL == "" {
+		config.APIBaseURL = DefaultAPIBaseURL
+	}
+	if config.DegradedMode == "" {
+		config.DegradedMode = DegradedModeFailClosed
+	}
+	if config.DegradedMode == DegradedModeFailOpen && config.DegradedGracePeriod == 0 {
+		config.DegradedGracePeriod = 900 // 15 minutes
+	}
+	if config.CircuitBreakerThreshold == 0 {
+		config.CircuitBreakerThreshold = infrastructure.DefaultFailureThreshold
+	}
+	if config.CircuitBreakerOpenTimeout == 0 {
+		config.CircuitBreakerOpenTimeout = int(infrastructure.DefaultOpenTimeout / time.Second)
+	}
+	if config.NegativeCacheTTL == 0 {
+		config.NegativeCacheTTL = 30
+	}
+	if config.PendingCacheTTL == 0 {
+		config.PendingCacheTTL = 5
+	}
+	if config.NegativeCacheSize == 0 {
+		config.NegativeCacheSize = usecase.DefaultNegativeCacheSize
+	}
 
-	// Create infrastructure components
-	sessionStore := infrastructure.NewSessionStore()
-	revokedSessionStore := infrastructure.NewRevokedSessionStore()
-	apiClient := infrastructure.NewAPIClient(config.RauthAPIKey, config.AppID)
+	// Create infrastructure components, preferring stores supplied as options
+	var sessionStore domain.SessionRepository = infrastructure.NewSessionStore()
+	if options.sessionStore != nil {
+		sessionStore = options.sessionStore
+	}
+	var revokedSessionStore domain.RevokedSessionRepository = infrastructure.NewRevokedSessionStore()
+	if options.revokedStore != nil {
+		revokedSessionStore = options.revokedStore
+	}
+
+	// The components of a previous Init stay in use until this one can no longer
+	// fail, so a failed Init leaves an initialized provider working. Its log is
+	// kept when the same file is configured again, rather than opened twice.
+	var revocationLog *filestore.RevokedSessionStore
+	if options.revocationFile != "" {
+		if options.revokedStore != nil {
+			return &domain.ConfigError{Field: "revocation_file", Message: "revocation file cannot be combined with a custom revoked store"}
+		}
+		if p.revocationLog != nil && p.options.revocationFile == options.revocationFile {
+			revocationLog = p.revocationLog
+		} else {
+			opened, err := filestore.Open(options.revocationFile)
+			if err != nil {
+				return &domain.ConfigError{Field: "revocation_file", Message: err.Error()}
+			}
+			revocationLog = opened
+		}
+		revokedSessionStore = revocationLog
+	}
+	// discard releases what this Init opened when it fails
+	discard := func() {
+		if revocationLog != nil && revocationLog != p.revocationLog {
+			revocationLog.Close(context.Background())
+		}
+	}
+
+	apiClient, err := newAPIClient(config, &options)
+	if err != nil {
+		discard()
+		return err
+	}
+	circuitBreaker := infrastructure.NewCircuitBreaker(apiClient, config.CircuitBreakerThreshold,
+		time.Duration(config.CircuitBreakerOpenTimeout)*time.Second)
 
 	// Convert public config to domain config
 	domainConfig := &domain.Config{
-		RauthAPIKey:      config.RauthAPIKey,
-		AppID:            config.AppID,
-		WebhookSecret:    config.WebhookSecret,
+		RauthAPIKey:       config.RauthAPIKey,
+		AppID:             config.AppID,
+		WebhookSecret:     config.WebhookSecret,
 		DefaultSessionTTL: config.DefaultSessionTTL,
 		DefaultRevokedTTL: config.DefaultRevokedTTL,
+
+		MaxSessionsPerPhone: config.MaxSessionsPerPhone,
+		SessionLimitPolicy:  config.SessionLimitPolicy,
+
+		IdleTimeout:        config.IdleTimeout,
+		MaxSessionLifetime: config.MaxSessionLifetime,
+
+		DegradedMode:        config.DegradedMode,
+		DegradedGracePeriod: config.DegradedGracePeriod,
+
+		NegativeCacheTTL:  config.NegativeCacheTTL,
+		PendingCacheTTL:   config.PendingCacheTTL,
+		NegativeCacheSize: config.NegativeCacheSize,
 	}
 
 	// Create use case layer
-	sessionService := usecase.NewSessionService(sessionStore, revokedSessionStore, apiClient, domainConfig)
+	sessionService := usecase.NewSessionService(sessionStore, revokedSessionStore, circuitBreaker, domainConfig)
+
+	// Subscribe to revocations from other instances
+	if options.broadcaster != nil {
+		if err := sessionService.Subscribe(context.Background(), options.broadcaster); err != nil {
+			apiClient.Close()
+			discard()
+			return err
+		}
+	}
 
 	// Create webhook handler
 	webhookHandler := delivery.NewWebhookHandler(config.WebhookSecret, sessionService)
 
+	// Nothing can fail anymore: retire the components of a previous Init,
+	// stopping its cleanup routine and subscription and closing its API client
+	// before replacing them
+	p.stopCleanupRoutine()
+	if p.sessionService != nil {
+		p.sessionService.Unsubscribe()
+	}
+	if p.apiClient != nil {
+		p.apiClient.Close()
+	}
+	if p.revocationLog != revocationLog {
+		p.closeRevocationLog()
+	}
+	p.revocationLog = revocationLog
+
 	// Set the components
 	p.config = config
 	p.sessionService = sessionService
 	p.apiClient = apiClient
+	p.circuitBreaker = circuitBreaker
 	p.webhookHandler = webhookHandler
+	p.options = options
 	p.initialized = true
 
 	// Start cleanup goroutine
-	go p.startCleanupRoutine()
+	p.stopCleanup = make(chan struct{})
+	p.cleanupDone = make(chan struct{})
+	go p.startCleanupRoutine(sessionService, options.cleanupInterval, p.stopCleanup, p.cleanupDone)
 
 	return nil
 }
 
+// Close stops the cleanup routine, waits for in-flight webhook requests,
+// closes idle API connections and flushes the session stores.
+// The provider can be initialized again after Close returns.
+func (p *RauthProvider) Close(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.initialized {
+		return nil
+	}
+	p.initialized = false
+
+	p.stopCleanupRoutine()
+
+	var firstErr error
+	if err := p.webhookHandler.Shutdown(ctx); err != nil {
+		firstErr = err
+	}
+
+	p.apiClient.Close()
+
+	if err := p.sessionService.Close(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	p.revocationLog = nil
+
+	return firstErr
+}
+
+// closeRevocationLog closes a revocation log opened by Init.
+// The caller must hold the write lock.
+func (p *RauthProvider) closeRevocationLog() {
+	if p.revocationLog == nil {
+		return
+	}
+	p.revocationLog.Close(context.Background())
+	p.revocationLog = nil
+}
+
 // validateConfig validates the configuration
 func (p *RauthProvider) validateConfig(config *Config) error {
 	if config == nil {
@@ -100,9 +270,100 @@ func (p *RauthProvider) validateConfig(config *Config) error {
 	if config.WebhookSecret == "" {
 		return &domain.ConfigError{Field: "webhook_secret", Message: "webhook secret is required"}
 	}
+	if config.MaxSessionsPerPhone < 0 {
+		return &domain.ConfigError{Field: "max_sessions_per_phone", Message: "max sessions per phone cannot be negative"}
+	}
+	if config.IdleTimeout < 0 {
+		return &domain.ConfigError{Field: "idle_timeout", Message: "idle timeout cannot be negative"}
+	}
+	if config.MaxSessionLifetime < 0 {
+		return &domain.ConfigError{Field: "max_session_lifetime", Message: "max session lifetime cannot be negative"}
+	}
+	switch config.SessionLimitPolicy {
+	case "", SessionLimitReject, SessionLimitEvictOldest:
+	default:
+		return &domain.ConfigError{Field: "session_limit_policy", Message: "session limit policy must be \"reject\" or \"evict_oldest\""}
+	}
+	if config.APIBaseURL != "" {
+		baseURL, err := url.Parse(config.APIBaseURL)
+		if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+			return &domain.ConfigError{Field: "api_base_url", Message: "API base URL must be an absolute http or https URL"}
+		}
+	}
+	if config.APITimeout < 0 {
+		return &domain.ConfigError{Field: "api_timeout", Message: "API timeout cannot be negative"}
+	}
+	if config.HealthCheckTimeout < 0 {
+		return &domain.ConfigError{Field: "health_check_timeout", Message: "health check timeout cannot be negative"}
+	}
+	switch config.DegradedMode {
+	case "", DegradedModeFailClosed, DegradedModeFailOpen:
+	default:
+		return &domain.ConfigError{Field: "degraded_mode", Message: "degraded mode must be \"fail_closed\" or \"fail_open\""}
+	}
+	if config.DegradedGracePeriod < 0 {
+		return &domain.ConfigError{Field: "degraded_grace_period", Message: "degraded grace period cannot be negative"}
+	}
+	if config.CircuitBreakerThreshold < 0 {
+		return &domain.ConfigError{Field: "circuit_breaker_threshold", Message: "circuit breaker threshold cannot be negative"}
+	}
+	if config.CircuitBreakerOpenTimeout < 0 {
+		return &domain.ConfigError{Field: "circuit_breaker_open_timeout", Message: "circuit breaker open timeout cannot be negative"}
+	}
+	if config.NegativeCacheTTL < 0 {
+		return &domain.ConfigError{Field: "negative_cache_ttl", Message: "negative cache TTL cannot be negative"}
+	}
+	if config.PendingCacheTTL < 0 {
+		return &domain.ConfigError{Field: "pending_cache_ttl", Message: "pending cache TTL cannot be negative"}
+	}
+	if config.NegativeCacheSize < 0 {
+		return &domain.ConfigError{Field: "negative_cache_size", Message: "negative cache size cannot be negative"}
+	}
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return &domain.ConfigError{Field: "tls_cert_file", Message: "client certificate and key files must be set together"}
+	}
 	return nil
 }
 
+// newAPIClient creates the Rauth API client from the configuration and options
+func newAPIClient(config *Config, options *options) (*infrastructure.APIClient, error) {
+	clientConfig := infrastructure.APIClientConfig{
+		BaseURL:              config.APIBaseURL,
+		HTTPClient:           options.httpClient,
+		Transport:            options.transport,
+		TLSConfig:            options.tlsConfig,
+		SessionStatusTimeout: time.Duration(config.APITimeout) * time.Second,
+		HealthCheckTimeout:   time.Duration(config.HealthCheckTimeout) * time.Second,
+		RetryPolicy:          options.retryPolicy,
+	}
+
+	policy := options.retryPolicy
+	if policy.MaxAttempts < 0 || policy.InitialBackoff < 0 || policy.MaxBackoff < 0 || policy.Budget < 0 {
+		return nil, &domain.ConfigError{Field: "retry_policy", Message: "retry policy values cannot be negative"}
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return nil, &domain.ConfigError{Field: "retry_policy", Message: "retry jitter must be between 0 and 1"}
+	}
+
+	customTLS := options.tlsConfig != nil || config.TLSCAFile != "" || config.TLSCertFile != ""
+	if options.httpClient != nil && options.transport != nil {
+		return nil, &domain.ConfigError{Field: "http_client", Message: "HTTP client cannot be combined with a custom transport"}
+	}
+	if (options.httpClient != nil || options.transport != nil) && customTLS {
+		return nil, &domain.ConfigError{Field: "tls", Message: "TLS settings cannot be combined with a custom HTTP client or transport"}
+	}
+
+	if config.TLSCAFile != "" || config.TLSCertFile != "" {
+		tlsConfig, err := infrastructure.LoadTLSConfig(options.tlsConfig, config.TLSCAFile, config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, &domain.ConfigError{Field: "tls", Message: err.Error()}
+		}
+		clientConfig.TLSConfig = tlsConfig
+	}
+
+	return infrastructure.NewAPIClient(config.RauthAPIKey, config.AppID, clientConfig), nil
+}
+
 // VerifySession verifies if a session is valid
 func (p *RauthProvider) VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, error) {
 	p.mutex.RLock()
@@ -115,6 +376,19 @@ func (p *RauthProvider) VerifySession(ctx context.Context, sessionToken, userPho
 	return p.sessionService.VerifySession(ctx, sessionToken, userPhone)
 }
 
+// GetSessionDetails returns the status, phone, channel and timestamps of a session
+// as reported by the Rauth API. Sessions revoked locally are reported as SessionStatusRevoked.
+func (p *RauthProvider) GetSessionDetails(ctx context.Context, sessionToken string) (*SessionDetails, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return nil, domain.ErrNotInitialized
+	}
+
+	return p.sessionService.GetSessionDetails(ctx, sessionToken)
+}
+
 // IsSessionRevoked checks if a session has been revoked
 func (p *RauthProvider) IsSessionRevoked(ctx context.Context, sessionToken string) (bool, error) {
 	p.mutex.RLock()
@@ -127,6 +401,95 @@ func (p *RauthProvider) IsSessionRevoked(ctx context.Context, sessionToken strin
 	return p.sessionService.IsSessionRevoked(ctx, sessionToken)
 }
 
+// RevokeSession revokes a session, e.g. when the user signs out, recording the
+// source and reason of the revocation. The revocation is broadcast to other instances.
+func (p *RauthProvider) RevokeSession(ctx context.Context, sessionToken string, revocation Revocation) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return domain.ErrNotInitialized
+	}
+
+	return p.sessionService.RevokeSession(ctx, sessionToken, revocation)
+}
+
+// GetRevocation returns when, by whom and why a session was revoked,
+// or ErrSessionNotFound when it is not revoked
+func (p *RauthProvider) GetRevocation(ctx context.Context, sessionToken string) (*store.RevokedSession, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return nil, domain.ErrNotInitialized
+	}
+
+	return p.sessionService.GetRevocation(ctx, sessionToken)
+}
+
+// RevokeAllForPhone revokes every known session of a phone number ("log out everywhere")
+// and returns the number of sessions revoked, recording the source and reason of the revocation
+func (p *RauthProvider) RevokeAllForPhone(ctx context.Context, phone string, revocation Revocation) (int, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return 0, domain.ErrNotInitialized
+	}
+
+	return p.sessionService.RevokeAllForPhone(ctx, phone, revocation)
+}
+
+// SetRevocationEpoch revokes every session created before notBefore, for one
+// phone number or for all sessions when phone is empty. The epoch is kept by the
+// configured revoked session store and broadcast to other instances.
+func (p *RauthProvider) SetRevocationEpoch(ctx context.Context, phone string, notBefore time.Time) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return domain.ErrNotInitialized
+	}
+
+	return p.sessionService.SetRevocationEpoch(ctx, phone, notBefore)
+}
+
+// GetSession returns the cached session of a token, including its metadata
+func (p *RauthProvider) GetSession(ctx context.Context, sessionToken string) (*store.Session, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return nil, domain.ErrNotInitialized
+	}
+
+	return p.sessionService.GetSession(ctx, sessionToken)
+}
+
+// ListSessions returns the active sessions of a phone number, oldest first
+func (p *RauthProvider) ListSessions(ctx context.Context, phone string) ([]*store.Session, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return nil, domain.ErrNotInitialized
+	}
+
+	return p.sessionService.ListSessions(ctx, phone)
+}
+
+// CountSessions returns the number of active sessions of a phone number
+func (p *RauthProvider) CountSessions(ctx context.Context, phone string) (int, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.initialized {
+		return 0, domain.ErrNotInitialized
+	}
+
+	return p.sessionService.CountSessions(ctx, phone)
+}
+
 // CheckAPIHealth checks if the Rauth API is reachable
 func (p *RauthProvider) CheckAPIHealth(ctx context.Context) (bool, error) {
 	p.mutex.RLock()
@@ -154,19 +517,38 @@ func (p *RauthProvider) WebhookHandler() http.HandlerFunc {
 }
 
 // startCleanupRoutine starts a background routine to clean up expired sessions
-func (p *RauthProvider) startCleanupRoutine() {
-	ticker := time.NewTicker(5 * time.Minute) // Run cleanup every 5 minutes
+func (p *RauthProvider) startCleanupRoutine(sessionService *usecase.SessionService, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		ctx := context.Background()
-		if err := p.sessionService.Cleanup(ctx); err != nil {
-			// Log error but continue
-			// In a production environment, you might want to use a proper logger
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx := context.Background()
+			if err := sessionService.Cleanup(ctx); err != nil {
+				// Log error but continue
+				// In a production environment, you might want to use a proper logger
+			}
 		}
 	}
 }
 
+// stopCleanupRoutine signals the cleanup routine to exit and waits for it.
+// The caller must hold the write lock.
+func (p *RauthProvider) stopCleanupRoutine() {
+	if p.stopCleanup == nil {
+		return
+	}
+	close(p.stopCleanup)
+	<-p.cleanupDone
+	p.stopCleanup = nil
+	p.cleanupDone = nil
+}
+
 // GetStats returns statistics about the provider
 func (p *RauthProvider) GetStats() map[string]interface{} {
 	p.mutex.RLock()
@@ -181,9 +563,52 @@ func (p *RauthProvider) GetStats() map[string]interface{} {
 	return map[string]interface{}{
 		"initialized": true,
 		"config": map[string]interface{}{
-			"app_id":              p.config.AppID,
-			"default_session_ttl": p.config.DefaultSessionTTL,
-			"default_revoked_ttl": p.config.DefaultRevokedTTL,
+			"app_id":                 p.config.AppID,
+			"default_session_ttl":    p.config.DefaultSessionTTL,
+			"default_revoked_ttl":    p.config.DefaultRevokedTTL,
+			"max_sessions_per_phone": p.config.MaxSessionsPerPhone,
+			"session_limit_policy":   p.config.SessionLimitPolicy,
+			"idle_timeout":           p.config.IdleTimeout,
+			"max_session_lifetime":   p.config.MaxSessionLifetime,
+			"api_base_url":           p.config.APIBaseURL,
+			"degraded_mode":          p.config.DegradedMode,
+			"degraded_grace_period":  p.config.DegradedGracePeriod,
+			"negative_cache_ttl":     p.config.NegativeCacheTTL,
+			"pending_cache_ttl":      p.config.PendingCacheTTL,
+			"negative_cache_size":    p.config.NegativeCacheSize,
+			"cleanup_interval":       p.options.cleanupInterval.String(),
 		},
+		"circuit_breaker": circuitBreakerStats(p.circuitBreaker.Stats()),
+		"coalescing":      coalescingStats(p.sessionService.CoalescingStats()),
+		"negative_cache":  negativeCacheStats(p.sessionService.NegativeCacheStats()),
+	}
+}
+
+// coalescingStats converts the counters of coalesced verifications for GetStats
+func coalescingStats(stats usecase.CoalescingStats) map[string]interface{} {
+	return map[string]interface{}{
+		"upstream_calls":  stats.Calls,
+		"coalesced_calls": stats.Coalesced,
+	}
+}
+
+// negativeCacheStats converts the state of the negative cache for GetStats
+func negativeCacheStats(stats usecase.NegativeCacheStats) map[string]interface{} {
+	return map[string]interface{}{
+		"entries": stats.Entries,
+		"hits":    stats.Hits,
+	}
+}
+
+// circuitBreakerStats converts the state of the circuit breaker for GetStats
+func circuitBreakerStats(stats infrastructure.CircuitBreakerStats) map[string]interface{} {
+	result := map[string]interface{}{
+		"state":                string(stats.State),
+		"consecutive_failures": stats.ConsecutiveFailures,
+		"rejected_requests":    stats.Rejected,
+	}
+	if !stats.OpenedAt.IsZero() {
+		result["opened_at"] = stats.OpenedAt.Format(time.RFC3339)
 	}
+	return result
 }
@@ -0,0 +1,551 @@
+package rauthprovider
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+func testConfig() *Config {
+	return &Config{
+		RauthAPIKey:   "test-api-key",
+		AppID:         "test-app-id",
+		WebhookSecret: "test-webhook-secret",
+		APIBaseURL:    stubAPIURL(),
+	}
+}
+
+var (
+	stubAPI     *httptest.Server
+	stubAPIOnce sync.Once
+)
+
+// stubAPIURL returns the base URL of a local Rauth API that knows no session,
+// shared by the tests that do not care about the API
+func stubAPIURL() string {
+	stubAPIOnce.Do(func() {
+		stubAPI = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status":"not_found"}`))
+		}))
+	})
+	return stubAPI.URL + "/session"
+}
+
+func TestNew_ReturnsIndependentProviders(t *testing.T) {
+	first, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer first.Close(context.Background())
+	second, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer second.Close(context.Background())
+
+	if first == second {
+		t.Fatal("New should return a distinct provider on every call")
+	}
+	if first.sessionService == second.sessionService {
+		t.Error("Providers should not share a session service")
+	}
+	if first.webhookHandler == second.webhookHandler {
+		t.Error("Providers should not share a webhook handler")
+	}
+
+	// Revoking on one provider must not leak into the other
+	ctx := context.Background()
+	if err := first.sessionService.RevokeSession(ctx, "shared-token", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if revoked, _ := first.IsSessionRevoked(ctx, "shared-token"); !revoked {
+		t.Error("Token should be revoked on the first provider")
+	}
+	if revoked, _ := second.IsSessionRevoked(ctx, "shared-token"); revoked {
+		t.Error("Token should not be revoked on the second provider")
+	}
+}
+
+func TestNew_ValidatesConfig(t *testing.T) {
+	config := testConfig()
+	config.AppID = ""
+
+	if _, err := New(config); !errors.Is(err, domain.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestNew_AppliesOptions(t *testing.T) {
+	p, err := New(testConfig(), WithCleanupInterval(0))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer p.Close(context.Background())
+
+	if p.options.cleanupInterval != defaultOptions().cleanupInterval {
+		t.Error("Non-positive cleanup interval should keep the default")
+	}
+}
+
+// waitForGoroutines waits until the goroutine count drops back to want
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Goroutines leaked: have %d, want %d", runtime.NumGoroutine(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClose_StopsCleanupRoutine(t *testing.T) {
+	stubAPIURL()
+	before := runtime.NumGoroutine()
+
+	p, err := New(testConfig(), WithCleanupInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	// Leave an idle connection in the API client that Init retires
+	if _, err := p.VerifySession(context.Background(), "token", "+1234567890"); err != nil {
+		t.Fatalf("VerifySession returned error: %v", err)
+	}
+
+	// Re-initializing must replace the cleanup routine and API client rather than add others
+	if err := p.Init(testConfig(), WithCleanupInterval(time.Millisecond)); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	waitForGoroutines(t, before)
+
+	if _, err := p.VerifySession(context.Background(), "token", "+1234567890"); err != domain.ErrNotInitialized {
+		t.Errorf("Expected ErrNotInitialized after Close, got %v", err)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Errorf("Second Close should be a no-op, got %v", err)
+	}
+}
+
+func TestClose_RejectsWebhooksAfterShutdown(t *testing.T) {
+	p, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	handler := p.WebhookHandler()
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"session_revoked","session_token":"token"}`))
+	req.Header.Set("x-webhook-secret", "test-webhook-secret")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d after Close, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+// recordingRevokedStore wraps the memory store and records Close calls
+type recordingRevokedStore struct {
+	store.RevokedSessionRepository
+	closed bool
+}
+
+func (s *recordingRevokedStore) Close(ctx context.Context) error {
+	s.closed = true
+	return nil
+}
+
+func TestWithStores_UsesCustomBackends(t *testing.T) {
+	sessionStore := store.NewMemorySessionStore()
+	revokedStore := &recordingRevokedStore{RevokedSessionRepository: store.NewMemoryRevokedSessionStore()}
+
+	p, err := New(testConfig(), WithSessionStore(sessionStore), WithRevokedStore(revokedStore))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := p.sessionService.RevokeSession(ctx, "custom-token", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if _, err := revokedStore.Get(ctx, "custom-token"); err != nil {
+		t.Errorf("Revocation should be written to the custom store, got %v", err)
+	}
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if !revokedStore.closed {
+		t.Error("Close should close stores implementing store.Closer")
+	}
+}
+
+func TestWithRevocationFile_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+	ctx := context.Background()
+
+	p, err := New(testConfig(), WithRevocationFile(path))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := p.sessionService.RevokeSession(ctx, "durable-token", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	restarted, err := New(testConfig(), WithRevocationFile(path))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer restarted.Close(ctx)
+
+	if revoked, _ := restarted.IsSessionRevoked(ctx, "durable-token"); !revoked {
+		t.Error("Revocation should be loaded from the log after a restart")
+	}
+}
+
+// failingBroadcaster refuses every subscription
+type failingBroadcaster struct{}
+
+func (failingBroadcaster) Publish(ctx context.Context, message *store.RevocationMessage) error {
+	return nil
+}
+
+func (failingBroadcaster) Subscribe(ctx context.Context, handler func(*store.RevocationMessage)) (func(), error) {
+	return nil, errors.New("broker unavailable")
+}
+
+func TestInit_FailureKeepsPreviousComponents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+	ctx := context.Background()
+	broadcaster := store.NewInProcessBroadcaster()
+
+	p, err := New(testConfig(), WithRevocationFile(path), WithRevocationBroadcaster(broadcaster))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer p.Close(ctx)
+	peer, err := New(testConfig(), WithRevocationBroadcaster(broadcaster))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer peer.Close(ctx)
+
+	missingCA := testConfig()
+	missingCA.TLSCAFile = filepath.Join(t.TempDir(), "missing.pem")
+	if err := p.Init(missingCA, WithRevocationFile(path), WithRevocationBroadcaster(broadcaster)); err == nil {
+		t.Fatal("Expected Init to fail with a missing CA file")
+	}
+	if err := p.Init(testConfig(), WithRevocationFile(path), WithRevocationBroadcaster(failingBroadcaster{})); err == nil {
+		t.Fatal("Expected Init to fail when the subscription fails")
+	}
+
+	// The provider keeps its revocation log and its subscription
+	if err := p.RevokeSession(ctx, "token-1", Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if err := peer.RevokeSession(ctx, "token-2", Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if revoked, _ := p.IsSessionRevoked(ctx, "token-2"); !revoked {
+		t.Error("Revocations of other instances should still be applied")
+	}
+
+	// Initializing again with the same file keeps the revocations
+	if err := p.Init(testConfig(), WithRevocationFile(path)); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+	if revoked, _ := p.IsSessionRevoked(ctx, "token-1"); !revoked {
+		t.Error("Revocations should survive initializing again with the same file")
+	}
+}
+
+func TestRevokeAllForPhone_RecordsRevocation(t *testing.T) {
+	p, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer p.Close(context.Background())
+	ctx := context.Background()
+
+	if err := p.sessionService.CacheSession(ctx, "token-1", "+1234567890", time.Now(), time.Hour); err != nil {
+		t.Fatalf("CacheSession returned error: %v", err)
+	}
+	count, err := p.RevokeAllForPhone(ctx, "+1234567890", Revocation{Source: RevocationSourceUserLogout, Reason: "signed out everywhere"})
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 revoked session, got %d (%v)", count, err)
+	}
+
+	revocation, err := p.GetRevocation(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetRevocation returned error: %v", err)
+	}
+	if revocation.Source != RevocationSourceUserLogout || revocation.Reason != "signed out everywhere" {
+		t.Errorf("Expected the given revocation details, got %+v", revocation)
+	}
+}
+
+func TestWithRevocationFile_ConflictsWithRevokedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+
+	_, err := New(testConfig(), WithRevocationFile(path), WithRevokedStore(store.NewMemoryRevokedSessionStore()))
+	if !errors.Is(err, domain.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestWithRevocationBroadcaster_RevokesOnAllInstances(t *testing.T) {
+	broadcaster := store.NewInProcessBroadcaster()
+	ctx := context.Background()
+
+	replicaA, err := New(testConfig(), WithRevocationBroadcaster(broadcaster))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer replicaA.Close(ctx)
+	replicaB, err := New(testConfig(), WithRevocationBroadcaster(broadcaster))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	// A webhook landing on replica A must revoke on replica B too
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"session_revoked","session_token":"token-1"}`))
+	req.Header.Set("x-webhook-secret", "test-webhook-secret")
+	rec := httptest.NewRecorder()
+	replicaA.WebhookHandler()(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if revoked, _ := replicaB.IsSessionRevoked(ctx, "token-1"); !revoked {
+		t.Error("Revocation should be propagated to replica B")
+	}
+
+	// Revocation details travel with the broadcast
+	if err := replicaA.RevokeSession(ctx, "token-3", Revocation{Source: RevocationSourceAdmin, Reason: "account locked"}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	revocation, err := replicaB.GetRevocation(ctx, "token-3")
+	if err != nil {
+		t.Fatalf("GetRevocation returned error: %v", err)
+	}
+	if revocation.Source != RevocationSourceAdmin || revocation.Reason != "account locked" {
+		t.Errorf("Expected the revocation details on replica B, got %+v", revocation)
+	}
+
+	// A closed replica no longer receives revocations
+	if err := replicaB.Close(ctx); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := replicaA.sessionService.RevokeSession(ctx, "token-2", domain.Revocation{}); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if revoked, _ := replicaB.sessionService.IsSessionRevoked(ctx, "token-2"); revoked {
+		t.Error("Closed replica should not apply broadcast revocations")
+	}
+}
+
+func TestWebhook_SessionCreatedPopulatesCache(t *testing.T) {
+	provider, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	ctx := context.Background()
+	defer provider.Close(ctx)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"session_created","session_token":"token-1","phone":"+1234567890","ttl":60}`))
+	req.Header.Set("x-webhook-secret", "test-webhook-secret")
+	rec := httptest.NewRecorder()
+	provider.WebhookHandler()(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Served from the cache, the test API key would fail against the real API
+	if verified, err := provider.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Errorf("Expected the announced session to verify from the cache, got %v, %v", verified, err)
+	}
+
+	session, err := provider.GetSession(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("GetSession returned error: %v", err)
+	}
+	if remaining := time.Until(session.ExpiresAt); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Expected the session to expire with the event TTL, got %v", remaining)
+	}
+}
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestAPI starts a stand-in for the Rauth API verifying every session of +1234567890
+func newTestAPI(handler func(http.HandlerFunc) *httptest.Server) *httptest.Server {
+	return handler(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/status":
+			w.Write([]byte(`{"status":"verified","phone":"+1234567890"}`))
+		case "/session/health":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestAPIBaseURL_AppliesToAllRequests(t *testing.T) {
+	server := newTestAPI(func(h http.HandlerFunc) *httptest.Server { return httptest.NewServer(h) })
+	defer server.Close()
+
+	config := testConfig()
+	config.APIBaseURL = server.URL + "/session/"
+	transport := &countingTransport{}
+	p, err := New(config, WithTransport(transport))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	ctx := context.Background()
+	defer p.Close(ctx)
+
+	if verified, err := p.VerifySession(ctx, "token-1", "+1234567890"); !verified || err != nil {
+		t.Errorf("VerifySession = %v, %v", verified, err)
+	}
+	if healthy, err := p.CheckAPIHealth(ctx); !healthy || err != nil {
+		t.Errorf("CheckAPIHealth = %v, %v", healthy, err)
+	}
+	if requests := atomic.LoadInt32(&transport.requests); requests != 2 {
+		t.Errorf("Expected both requests to go through the custom transport, got %d", requests)
+	}
+}
+
+func TestTLSCAFile_TrustsCustomCA(t *testing.T) {
+	server := newTestAPI(func(h http.HandlerFunc) *httptest.Server { return httptest.NewTLSServer(h) })
+	defer server.Close()
+	ctx := context.Background()
+
+	config := testConfig()
+	config.APIBaseURL = server.URL + "/session"
+	untrusted, err := New(config)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer untrusted.Close(ctx)
+	if healthy, err := untrusted.CheckAPIHealth(ctx); healthy || err == nil {
+		t.Error("Expected the test certificate to be rejected without its CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	config = testConfig()
+	config.APIBaseURL = server.URL + "/session"
+	config.TLSCAFile = caFile
+	trusted, err := New(config)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer trusted.Close(ctx)
+	if healthy, err := trusted.CheckAPIHealth(ctx); !healthy || err != nil {
+		t.Errorf("CheckAPIHealth = %v, %v", healthy, err)
+	}
+}
+
+func TestNew_ValidatesAPIClientSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+		opts   []Option
+	}{
+		{"relative base URL", func(c *Config) { c.APIBaseURL = "api.rauth.io/session" }, nil},
+		{"negative timeout", func(c *Config) { c.APITimeout = -1 }, nil},
+		{"certificate without key", func(c *Config) { c.TLSCertFile = "client.pem" }, nil},
+		{"missing CA file", func(c *Config) { c.TLSCAFile = filepath.Join(t.TempDir(), "missing.pem") }, nil},
+		{"client and transport", func(c *Config) {}, []Option{WithHTTPClient(http.DefaultClient), WithTransport(http.DefaultTransport)}},
+		{"transport and TLS", func(c *Config) { c.TLSCAFile = "ca.pem" }, []Option{WithTransport(http.DefaultTransport)}},
+		{"negative retry attempts", func(c *Config) {}, []Option{WithRetryPolicy(RetryPolicy{MaxAttempts: -1})}},
+		{"retry jitter above 1", func(c *Config) {}, []Option{WithRetryPolicy(RetryPolicy{Jitter: 1.5})}},
+		{"unknown degraded mode", func(c *Config) { c.DegradedMode = "fail_sometimes" }, nil},
+		{"negative grace period", func(c *Config) { c.DegradedGracePeriod = -1 }, nil},
+		{"negative breaker threshold", func(c *Config) { c.CircuitBreakerThreshold = -1 }, nil},
+		{"negative cache size", func(c *Config) { c.NegativeCacheSize = -1 }, nil},
+	}
+
+	for _, test := range tests {
+		config := testConfig()
+		test.config(config)
+		if _, err := New(config, test.opts...); !errors.Is(err, domain.ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", test.name, err)
+		}
+	}
+}
+
+func TestCircuitBreaker_FailsFastDuringOutages(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := testConfig()
+	config.APIBaseURL = server.URL + "/session"
+	config.CircuitBreakerThreshold = 2
+	p, err := New(config, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	ctx := context.Background()
+	defer p.Close(ctx)
+
+	for i := 0; i < 4; i++ {
+		if verified, err := p.VerifySession(ctx, "token-1", "+1234567890"); verified || !errors.Is(err, ErrAPIUnreachable) {
+			t.Errorf("VerifySession = %v, %v", verified, err)
+		}
+	}
+	if _, err := p.VerifySession(ctx, "token-1", "+1234567890"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 2 {
+		t.Errorf("Expected the API to be called until the circuit opened, got %d requests", requests)
+	}
+
+	stats := p.GetStats()["circuit_breaker"].(map[string]interface{})
+	if stats["state"] != "open" {
+		t.Errorf("Expected state open, got %v", stats["state"])
+	}
+	if stats["rejected_requests"] != int64(3) {
+		t.Errorf("Expected 3 rejected requests, got %v", stats["rejected_requests"])
+	}
+}
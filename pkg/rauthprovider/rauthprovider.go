@@ -8,25 +8,54 @@
 // - HTTP Middleware integration
 // - Signature-based verification
 // - In-memory session tracking with API fallback
-// - Singleton pattern for state management
+// - Independent provider instances via New, with a default instance for the package-level helpers
 package rauthprovider
 
 import (
 	"context"
 	"net/http"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
 )
 
 // Provider is the main interface for Rauth authentication
 type Provider interface {
 	// Init initializes the provider with configuration
-	Init(config *Config) error
+	Init(config *Config, opts ...Option) error
 
 	// VerifySession verifies if a session is valid
 	VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, error)
 
+	// GetSessionDetails returns the status and details of a session from the Rauth API
+	GetSessionDetails(ctx context.Context, sessionToken string) (*SessionDetails, error)
+
 	// IsSessionRevoked checks if a session has been revoked
 	IsSessionRevoked(ctx context.Context, sessionToken string) (bool, error)
 
+	// RevokeSession revokes a session, recording who revoked it and why
+	RevokeSession(ctx context.Context, sessionToken string, revocation Revocation) error
+
+	// GetRevocation returns the details of a session's revocation
+	GetRevocation(ctx context.Context, sessionToken string) (*store.RevokedSession, error)
+
+	// RevokeAllForPhone revokes every known session of a phone number, recording who revoked them and why
+	RevokeAllForPhone(ctx context.Context, phone string, revocation Revocation) (int, error)
+
+	// SetRevocationEpoch revokes every session created before notBefore,
+	// for one phone number or for all sessions when phone is empty
+	SetRevocationEpoch(ctx context.Context, phone string, notBefore time.Time) error
+
+	// GetSession returns the cached session of a token
+	GetSession(ctx context.Context, sessionToken string) (*store.Session, error)
+
+	// ListSessions returns the active sessions of a phone number
+	ListSessions(ctx context.Context, phone string) ([]*store.Session, error)
+
+	// CountSessions returns the number of active sessions of a phone number
+	CountSessions(ctx context.Context, phone string) (int, error)
+
 	// CheckAPIHealth checks if the Rauth API is reachable
 	CheckAPIHealth(ctx context.Context) (bool, error)
 
@@ -35,16 +64,19 @@ type Provider interface {
 
 	// GetStats returns statistics about the provider
 	GetStats() map[string]interface{}
+
+	// Close shuts down background work and releases resources
+	Close(ctx context.Context) error
 }
 
-// GetProvider returns the singleton provider instance
+// GetProvider returns the default provider instance
 func GetProvider() Provider {
 	return GetInstance()
 }
 
-// Init is a convenience function to initialize the provider
-func Init(config *Config) error {
-	return GetInstance().Init(config)
+// Init is a convenience function to initialize the default provider
+func Init(config *Config, opts ...Option) error {
+	return GetInstance().Init(config, opts...)
 }
 
 // VerifySession is a convenience function to verify a session
@@ -52,11 +84,63 @@ func VerifySession(ctx context.Context, sessionToken, userPhone string) (bool, e
 	return GetInstance().VerifySession(ctx, sessionToken, userPhone)
 }
 
+// GetSessionDetails is a convenience function to get the details of a session
+func GetSessionDetails(ctx context.Context, sessionToken string) (*SessionDetails, error) {
+	return GetInstance().GetSessionDetails(ctx, sessionToken)
+}
+
 // IsSessionRevoked is a convenience function to check if a session is revoked
 func IsSessionRevoked(ctx context.Context, sessionToken string) (bool, error) {
 	return GetInstance().IsSessionRevoked(ctx, sessionToken)
 }
 
+// RevokeSession is a convenience function to revoke a session
+func RevokeSession(ctx context.Context, sessionToken string, revocation Revocation) error {
+	return GetInstance().RevokeSession(ctx, sessionToken, revocation)
+}
+
+// GetRevocation is a convenience function to get the details of a session's revocation
+func GetRevocation(ctx context.Context, sessionToken string) (*store.RevokedSession, error) {
+	return GetInstance().GetRevocation(ctx, sessionToken)
+}
+
+// RevokeAllForPhone is a convenience function to revoke every session of a phone number
+func RevokeAllForPhone(ctx context.Context, phone string, revocation Revocation) (int, error) {
+	return GetInstance().RevokeAllForPhone(ctx, phone, revocation)
+}
+
+// SetRevocationEpoch is a convenience function to revoke every session created before an instant
+func SetRevocationEpoch(ctx context.Context, phone string, notBefore time.Time) error {
+	return GetInstance().SetRevocationEpoch(ctx, phone, notBefore)
+}
+
+// GetSession is a convenience function to inspect the cached session of a token
+func GetSession(ctx context.Context, sessionToken string) (*store.Session, error) {
+	return GetInstance().GetSession(ctx, sessionToken)
+}
+
+// ContextWithSessionMetadata returns a context carrying metadata that is recorded
+// on a session when it is first verified with VerifySession
+func ContextWithSessionMetadata(ctx context.Context, metadata store.SessionMetadata) context.Context {
+	return domain.ContextWithMetadata(ctx, metadata)
+}
+
+// ContextWithExpiryPolicy returns a context that applies policy instead of the configured
+// idle timeout and max session lifetime when VerifySession checks a session with it
+func ContextWithExpiryPolicy(ctx context.Context, policy ExpiryPolicy) context.Context {
+	return domain.ContextWithExpiryPolicy(ctx, policy)
+}
+
+// ListSessions is a convenience function to list the active sessions of a phone number
+func ListSessions(ctx context.Context, phone string) ([]*store.Session, error) {
+	return GetInstance().ListSessions(ctx, phone)
+}
+
+// CountSessions is a convenience function to count the active sessions of a phone number
+func CountSessions(ctx context.Context, phone string) (int, error) {
+	return GetInstance().CountSessions(ctx, phone)
+}
+
 // CheckAPIHealth is a convenience function to check API health
 func CheckAPIHealth(ctx context.Context) (bool, error) {
 	return GetInstance().CheckAPIHealth(ctx)
@@ -71,3 +155,8 @@ func WebhookHandler() http.HandlerFunc {
 func GetStats() map[string]interface{} {
 	return GetInstance().GetStats()
 }
+
+// Close is a convenience function to shut down the default provider
+func Close(ctx context.Context) error {
+	return GetInstance().Close(ctx)
+}
@@ -0,0 +1,22 @@
+package rauthprovider
+
+import (
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// Revocation describes why and by whom a session is revoked, see RevokeSession
+type Revocation = domain.Revocation
+
+// Sources of a revocation, reported by GetRevocation
+const (
+	// RevocationSourceWebhook marks sessions revoked by Rauth through a webhook
+	RevocationSourceWebhook = domain.RevocationSourceWebhook
+	// RevocationSourceAdmin marks sessions revoked by an administrator of the application
+	RevocationSourceAdmin = domain.RevocationSourceAdmin
+	// RevocationSourceUserLogout marks sessions the user signed out of, possibly on another device
+	RevocationSourceUserLogout = domain.RevocationSourceUserLogout
+	// RevocationSourceEviction marks sessions evicted to stay within MaxSessionsPerPhone
+	RevocationSourceEviction = domain.RevocationSourceEviction
+	// RevocationSourceEpoch marks sessions created before a revocation epoch
+	RevocationSourceEpoch = domain.RevocationSourceEpoch
+)
@@ -0,0 +1,25 @@
+package rauthprovider
+
+import (
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+)
+
+// SessionDetails describes a session as reported by the Rauth API, see GetSessionDetails
+type SessionDetails = domain.SessionDetails
+
+// SessionStatus is the status of a session reported by the Rauth API
+type SessionStatus = domain.SessionStatus
+
+// Statuses of a session, reported by GetSessionDetails
+const (
+	// SessionStatusVerified marks sessions whose phone number has been verified
+	SessionStatusVerified = domain.SessionStatusVerified
+	// SessionStatusPending marks sessions still waiting for the user to verify
+	SessionStatusPending = domain.SessionStatusPending
+	// SessionStatusExpired marks sessions past their expiry
+	SessionStatusExpired = domain.SessionStatusExpired
+	// SessionStatusRevoked marks sessions revoked by Rauth or by this application
+	SessionStatusRevoked = domain.SessionStatusRevoked
+	// SessionStatusNotFound marks tokens unknown to the Rauth API
+	SessionStatusNotFound = domain.SessionStatusNotFound
+)
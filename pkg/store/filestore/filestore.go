@@ -0,0 +1,255 @@
+// Package filestore provides a revoked session store that persists revocations
+// in an append-only log file, so they survive restarts without an external database.
+//
+// Each revocation and revocation epoch is appended as one JSON line and synced
+// to disk before the write returns. The log is replayed when the store is opened
+// and compacted, dropping expired records, on open and during Cleanup.
+package filestore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+// epochRecord is the log record of a revocation epoch
+type epochRecord struct {
+	Scope     string    `json:"scope"`
+	NotBefore time.Time `json:"not_before"`
+}
+
+// logRecord is one line of the log: a revoked session, or an epoch when Epoch is set
+type logRecord struct {
+	Epoch *epochRecord `json:"epoch,omitempty"`
+	store.RevokedSession
+}
+
+// RevokedSessionStore implements store.RevokedSessionRepository backed by a log file
+type RevokedSessionStore struct {
+	path            string
+	file            *os.File
+	revokedSessions map[string]*store.RevokedSession
+	notBefore       map[string]time.Time
+	mutex           sync.RWMutex
+}
+
+// Open loads the revocation log at path, creating it if needed, and returns a store appending to it
+func Open(path string) (*RevokedSessionStore, error) {
+	s := &RevokedSessionStore{
+		path:            path,
+		revokedSessions: make(map[string]*store.RevokedSession),
+		notBefore:       make(map[string]time.Time),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	// Compacting on open also discards a partially written last line left by a crash
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load replays the log into memory, skipping expired and unreadable records
+func (s *RevokedSessionStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open revocation log: %w", err)
+	}
+	defer file.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.Epoch != nil {
+			if record.Epoch.NotBefore.After(s.notBefore[record.Epoch.Scope]) {
+				s.notBefore[record.Epoch.Scope] = record.Epoch.NotBefore
+			}
+			continue
+		}
+		if now.After(record.ExpiresAt) {
+			continue
+		}
+		revokedSession := record.RevokedSession
+		s.revokedSessions[revokedSession.Token] = &revokedSession
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read revocation log: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the log with the live records only and reopens it for appending.
+// The caller must hold the write lock or have exclusive access.
+func (s *RevokedSessionStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted revocation log: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for scope, notBefore := range s.notBefore {
+		if err := encoder.Encode(logRecord{Epoch: &epochRecord{Scope: scope, NotBefore: notBefore}}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write compacted revocation log: %w", err)
+		}
+	}
+	for _, revokedSession := range s.revokedSessions {
+		if err := encoder.Encode(revokedSession); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write compacted revocation log: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write compacted revocation log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compacted revocation log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close compacted revocation log: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace revocation log: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open revocation log: %w", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	return nil
+}
+
+// appendLocked appends a record to the log and syncs it to disk.
+// The caller must hold the write lock.
+func (s *RevokedSessionStore) appendLocked(record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation record: %w", err)
+	}
+	data = append(data, '\n')
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("failed to append to revocation log: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync revocation log: %w", err)
+	}
+	return nil
+}
+
+// Store appends a revoked session to the log
+func (s *RevokedSessionStore) Store(ctx context.Context, revokedSession *store.RevokedSession) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.appendLocked(revokedSession); err != nil {
+		return err
+	}
+
+	s.revokedSessions[revokedSession.Token] = revokedSession
+	return nil
+}
+
+// Get retrieves a revoked session by token
+func (s *RevokedSessionStore) Get(ctx context.Context, token string) (*store.RevokedSession, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	revokedSession, exists := s.revokedSessions[token]
+	if !exists || time.Now().After(revokedSession.ExpiresAt) {
+		return nil, store.ErrSessionNotFound
+	}
+
+	return revokedSession, nil
+}
+
+// SetNotBefore appends a revocation epoch to the log unless a later one is already set
+func (s *RevokedSessionStore) SetNotBefore(ctx context.Context, scope string, notBefore time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !notBefore.After(s.notBefore[scope]) {
+		return nil
+	}
+	if err := s.appendLocked(logRecord{Epoch: &epochRecord{Scope: scope, NotBefore: notBefore}}); err != nil {
+		return err
+	}
+
+	s.notBefore[scope] = notBefore
+	return nil
+}
+
+// GetNotBefore returns the epoch of scope, or the zero time when none is set
+func (s *RevokedSessionStore) GetNotBefore(ctx context.Context, scope string) (time.Time, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.notBefore[scope], nil
+}
+
+// Cleanup removes expired revoked sessions and compacts the log
+func (s *RevokedSessionStore) Cleanup(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	now := time.Now()
+	for token, revokedSession := range s.revokedSessions {
+		if now.After(revokedSession.ExpiresAt) {
+			delete(s.revokedSessions, token)
+		}
+	}
+
+	return s.compact()
+}
+
+// Close syncs and closes the log file
+func (s *RevokedSessionStore) Close(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Sync()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
@@ -0,0 +1,156 @@
+package filestore
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+var _ store.RevokedSessionRepository = (*RevokedSessionStore)(nil)
+
+// countLines returns the number of records in the log file
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestRevokedSessionStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+	ctx := context.Background()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	now := time.Now()
+	if err := s.Store(ctx, &store.RevokedSession{Token: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer reopened.Close(ctx)
+
+	got, err := reopened.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Revocation should survive a restart, got %v", err)
+	}
+	if !got.RevokedAt.Equal(now) {
+		t.Errorf("Expected RevokedAt %v, got %v", now, got.RevokedAt)
+	}
+	if _, err := reopened.Get(ctx, "token-2"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestRevokedSessionStore_NotBeforeSurvivesCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+	ctx := context.Background()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	now := time.Now()
+	s.SetNotBefore(ctx, "", now)
+	s.SetNotBefore(ctx, "", now.Add(-time.Hour))
+	s.SetNotBefore(ctx, "+1234567890", now.Add(-time.Minute))
+	if err := s.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup returned error: %v", err)
+	}
+	s.Close(ctx)
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer reopened.Close(ctx)
+
+	if notBefore, _ := reopened.GetNotBefore(ctx, ""); !notBefore.Equal(now) {
+		t.Errorf("Expected global epoch %v, got %v", now, notBefore)
+	}
+	if notBefore, _ := reopened.GetNotBefore(ctx, "+1234567890"); !notBefore.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Unexpected phone epoch %v", notBefore)
+	}
+}
+
+func TestRevokedSessionStore_CleanupCompactsLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+	ctx := context.Background()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer s.Close(ctx)
+
+	now := time.Now()
+	s.Store(ctx, &store.RevokedSession{Token: "active", RevokedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.RevokedSession{Token: "active", RevokedAt: now, ExpiresAt: now.Add(2 * time.Hour)})
+	s.Store(ctx, &store.RevokedSession{Token: "expiring", RevokedAt: now, ExpiresAt: now.Add(10 * time.Millisecond)})
+
+	if lines := countLines(t, path); lines != 3 {
+		t.Fatalf("Expected 3 appended records, got %d", lines)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup returned error: %v", err)
+	}
+
+	if lines := countLines(t, path); lines != 1 {
+		t.Errorf("Expected 1 record after compaction, got %d", lines)
+	}
+
+	// The store keeps appending to the compacted log
+	s.Store(ctx, &store.RevokedSession{Token: "later", RevokedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if lines := countLines(t, path); lines != 2 {
+		t.Errorf("Expected 2 records after appending, got %d", lines)
+	}
+}
+
+func TestOpen_IgnoresTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.log")
+	expires := time.Now().Add(time.Hour).Format(time.RFC3339Nano)
+	data := `{"token":"token-1","revoked_at":"2024-01-01T00:00:00Z","expires_at":"` + expires + `"}` + "\n" + `{"token":"tok`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer s.Close(context.Background())
+
+	if _, err := s.Get(context.Background(), "token-1"); err != nil {
+		t.Errorf("Complete records should be loaded, got %v", err)
+	}
+	if lines := countLines(t, path); lines != 1 {
+		t.Errorf("Truncated record should be dropped on open, got %d lines", lines)
+	}
+}
@@ -0,0 +1,68 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+// Broadcaster implements store.RevocationBroadcaster over Redis pub/sub
+type Broadcaster struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewBroadcaster creates a Redis pub/sub broadcaster for the given app
+func NewBroadcaster(client redis.UniversalClient, appID string) *Broadcaster {
+	return &Broadcaster{
+		client:  client,
+		channel: keyPrefix(appID) + "revocations",
+	}
+}
+
+// Publish sends a revocation to every subscribed instance
+func (b *Broadcaster) Publish(ctx context.Context, message *store.RevocationMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation message: %w", err)
+	}
+
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe registers a handler for revocations published by any instance
+func (b *Broadcaster) Subscribe(ctx context.Context, handler func(*store.RevocationMessage)) (func(), error) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+
+	// Wait for the subscription to be confirmed so no message published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to revocations: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for msg := range pubsub.Channel() {
+			var message store.RevocationMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+				continue
+			}
+			handler(&message)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pubsub.Close()
+			wg.Wait()
+		})
+	}, nil
+}
@@ -0,0 +1,65 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+func TestBroadcaster_DeliversToSubscribers(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	received := make(chan *store.RevocationMessage, 1)
+	subscriber := NewBroadcaster(client, "app-1")
+	unsubscribe, err := subscriber.Subscribe(ctx, func(message *store.RevocationMessage) {
+		received <- message
+	})
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	defer unsubscribe()
+
+	now := time.Now()
+	publisher := NewBroadcaster(client, "app-1")
+	if err := publisher.Publish(ctx, &store.RevocationMessage{Origin: "replica-a", Token: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+
+	select {
+	case message := <-received:
+		if message.Token != "token-1" || message.Origin != "replica-a" {
+			t.Errorf("Unexpected message %+v", message)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the revocation")
+	}
+}
+
+func TestBroadcaster_UnsubscribeStopsDelivery(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	received := make(chan *store.RevocationMessage, 1)
+	b := NewBroadcaster(client, "app-1")
+	unsubscribe, err := b.Subscribe(ctx, func(message *store.RevocationMessage) {
+		received <- message
+	})
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	unsubscribe()
+	unsubscribe()
+
+	if err := b.Publish(ctx, &store.RevocationMessage{Token: "token-1"}); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+
+	select {
+	case message := <-received:
+		t.Errorf("Unexpected message after unsubscribe: %+v", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
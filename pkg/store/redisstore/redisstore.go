@@ -0,0 +1,348 @@
+// Package redisstore provides Redis-backed session and revoked session stores,
+// so that every replica of a service shares the same sessions and revocations.
+//
+// Records are stored as JSON under keys prefixed with the Rauth app ID and
+// expire through native Redis key TTLs, so Cleanup is a no-op.
+// The stores do not own the Redis client; close it yourself after the provider.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/phone"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+// keyPrefix returns the key prefix shared by all records of an app
+func keyPrefix(appID string) string {
+	return "rauth:" + appID + ":"
+}
+
+// SessionStore implements store.SessionRepository on top of Redis
+type SessionStore struct {
+	client      redis.UniversalClient
+	prefix      string
+	phonePrefix string
+}
+
+// NewSessionStore creates a Redis session store for the given app
+func NewSessionStore(client redis.UniversalClient, appID string) *SessionStore {
+	return &SessionStore{
+		client:      client,
+		prefix:      keyPrefix(appID) + "session:",
+		phonePrefix: keyPrefix(appID) + "phone:",
+	}
+}
+
+// Store stores a session until its expiry and indexes it by phone number.
+// The phone index set lives as long as the longest session it holds;
+// members whose session has expired are pruned when the index is read.
+func (s *SessionStore) Store(ctx context.Context, session *store.Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	phoneKey := s.phoneKey(session.UserPhone)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.prefix+session.Token, data, ttl)
+		pipe.SAdd(ctx, phoneKey, session.Token)
+		extendTTL(ctx, pipe, phoneKey, ttl)
+		return nil
+	})
+	return err
+}
+
+// Get retrieves a session by token
+func (s *SessionStore) Get(ctx context.Context, token string) (*store.Session, error) {
+	data, err := s.client.Get(ctx, s.prefix+token).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, store.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session store.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// Delete removes a session and its phone index entry
+func (s *SessionStore) Delete(ctx context.Context, token string) error {
+	session, err := s.Get(ctx, token)
+	if err == store.ErrSessionNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.prefix+token)
+		pipe.SRem(ctx, s.phoneKey(session.UserPhone), token)
+		return nil
+	})
+	return err
+}
+
+// extendTTLScript sets the TTL of a key in milliseconds when it has none or a
+// shorter one, like EXPIRE NX and EXPIRE GT together, which need Redis 7
+const extendTTLScript = `
+local current = redis.call("PTTL", KEYS[1])
+if current == -1 or (current >= 0 and current < tonumber(ARGV[1])) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return 1
+`
+
+// extendTTL queues extendTTLScript for key. The script is sent in full rather
+// than by SHA, since EVALSHA cannot fall back to EVAL inside a transaction.
+func extendTTL(ctx context.Context, pipe redis.Pipeliner, key string, ttl time.Duration) {
+	pipe.Eval(ctx, extendTTLScript, []string{key}, ttl.Milliseconds())
+}
+
+// phoneKey returns the key of the index set of a phone number, see phone.Key
+func (s *SessionStore) phoneKey(number string) string {
+	return s.phonePrefix + phone.Key(number)
+}
+
+// sessionsByPhone returns the unexpired sessions of a phone number together
+// with every member of its index set, including stale ones
+func (s *SessionStore) sessionsByPhone(ctx context.Context, phone string) ([]*store.Session, []string, error) {
+	members, err := s.client.SMembers(ctx, s.phoneKey(phone)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil, nil
+	}
+
+	keys := make([]string, len(members))
+	for i, token := range members {
+		keys[i] = s.prefix + token
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessions := make([]*store.Session, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var session store.Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			continue
+		}
+		// The token may have been stored again under another phone
+		if s.phoneKey(session.UserPhone) == s.phoneKey(phone) {
+			sessions = append(sessions, &session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, members, nil
+}
+
+// DeleteByPhone removes every session of a phone number and returns their tokens
+func (s *SessionStore) DeleteByPhone(ctx context.Context, phone string) ([]string, error) {
+	sessions, members, err := s.sessionsByPhone(ctx, phone)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	tokens := make([]string, len(sessions))
+	for i, session := range sessions {
+		tokens[i] = session.Token
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, token := range tokens {
+			pipe.Del(ctx, s.prefix+token)
+		}
+		pipe.SRem(ctx, s.phoneKey(phone), stringsToInterfaces(members)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// ListSessions returns the unexpired sessions of a phone number, oldest first
+func (s *SessionStore) ListSessions(ctx context.Context, phone string) ([]*store.Session, error) {
+	sessions, _, err := s.sessionsByPhone(ctx, phone)
+	return sessions, err
+}
+
+// CountSessions returns the number of unexpired sessions of a phone number
+func (s *SessionStore) CountSessions(ctx context.Context, phone string) (int, error) {
+	sessions, _, err := s.sessionsByPhone(ctx, phone)
+	return len(sessions), err
+}
+
+// Touch sets the last seen time and expiry of an unexpired session.
+// A session written concurrently by another request keeps that write.
+func (s *SessionStore) Touch(ctx context.Context, token string, lastSeenAt, expiresAt time.Time) error {
+	key := s.prefix + token
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return store.ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var session store.Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return fmt.Errorf("failed to unmarshal session: %w", err)
+		}
+		session.LastSeenAt = lastSeenAt
+		session.ExpiresAt = expiresAt
+
+		ttl := time.Until(expiresAt)
+		if ttl <= 0 {
+			return nil
+		}
+		if data, err = json.Marshal(&session); err != nil {
+			return fmt.Errorf("failed to marshal session: %w", err)
+		}
+
+		phoneKey := s.phoneKey(session.UserPhone)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, ttl)
+			extendTTL(ctx, pipe, phoneKey, ttl)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil
+	}
+	return err
+}
+
+// stringsToInterfaces converts strings into variadic command arguments
+func stringsToInterfaces(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+// Cleanup is a no-op, expired sessions are removed by Redis key TTLs
+func (s *SessionStore) Cleanup(ctx context.Context) error {
+	return nil
+}
+
+// RevokedSessionStore implements store.RevokedSessionRepository on top of Redis
+type RevokedSessionStore struct {
+	client      redis.UniversalClient
+	prefix      string
+	epochPrefix string
+}
+
+// NewRevokedSessionStore creates a Redis revoked session store for the given app
+func NewRevokedSessionStore(client redis.UniversalClient, appID string) *RevokedSessionStore {
+	return &RevokedSessionStore{
+		client:      client,
+		prefix:      keyPrefix(appID) + "revoked:",
+		epochPrefix: keyPrefix(appID) + "notbefore:",
+	}
+}
+
+// Store stores a revoked session until its expiry
+func (s *RevokedSessionStore) Store(ctx context.Context, revokedSession *store.RevokedSession) error {
+	ttl := time.Until(revokedSession.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(revokedSession)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revoked session: %w", err)
+	}
+
+	return s.client.Set(ctx, s.prefix+revokedSession.Token, data, ttl).Err()
+}
+
+// Get retrieves a revoked session by token
+func (s *RevokedSessionStore) Get(ctx context.Context, token string) (*store.RevokedSession, error) {
+	data, err := s.client.Get(ctx, s.prefix+token).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, store.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revokedSession store.RevokedSession
+	if err := json.Unmarshal(data, &revokedSession); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revoked session: %w", err)
+	}
+
+	return &revokedSession, nil
+}
+
+// setNotBeforeScript stores an epoch in Unix nanoseconds unless a later one is already set
+var setNotBeforeScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current or tonumber(current) < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// notBeforeKey returns the key holding the epoch of scope
+func (s *RevokedSessionStore) notBeforeKey(scope string) string {
+	if scope == "" {
+		return s.epochPrefix + "global"
+	}
+	return s.epochPrefix + "phone:" + scope
+}
+
+// SetNotBefore revokes every session of scope created before notBefore.
+// Epochs never expire.
+func (s *RevokedSessionStore) SetNotBefore(ctx context.Context, scope string, notBefore time.Time) error {
+	return setNotBeforeScript.Run(ctx, s.client, []string{s.notBeforeKey(scope)}, notBefore.UnixNano()).Err()
+}
+
+// GetNotBefore returns the epoch of scope, or the zero time when none is set
+func (s *RevokedSessionStore) GetNotBefore(ctx context.Context, scope string) (time.Time, error) {
+	nanos, err := s.client.Get(ctx, s.notBeforeKey(scope)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// Cleanup is a no-op, expired revoked sessions are removed by Redis key TTLs
+func (s *RevokedSessionStore) Cleanup(ctx context.Context) error {
+	return nil
+}
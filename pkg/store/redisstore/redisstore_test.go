@@ -0,0 +1,260 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+var (
+	_ store.SessionRepository        = (*SessionStore)(nil)
+	_ store.RevokedSessionRepository = (*RevokedSessionStore)(nil)
+	_ store.RevocationBroadcaster    = (*Broadcaster)(nil)
+)
+
+// newTestClient starts a local Redis stand-in and returns a client connected to it
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestSessionStore_StoreGetDelete(t *testing.T) {
+	mr, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	session := &store.Session{
+		Token:     "token-1",
+		UserPhone: "+1234567890",
+		CreatedAt: now,
+		ExpiresAt: now.Add(15 * time.Minute),
+	}
+	if err := s.Store(ctx, session); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	if !mr.Exists("rauth:app-1:session:token-1") {
+		t.Error("Session key should be prefixed with the app ID")
+	}
+	if ttl := mr.TTL("rauth:app-1:session:token-1"); ttl <= 0 || ttl > 15*time.Minute {
+		t.Errorf("Session key should expire with the session, got TTL %v", ttl)
+	}
+
+	got, err := s.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.UserPhone != session.UserPhone || !got.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("Get returned %+v, want %+v", got, session)
+	}
+
+	if err := s.Delete(ctx, "token-1"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := s.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after Delete, got %v", err)
+	}
+}
+
+func TestSessionStore_ExpiresThroughTTL(t *testing.T) {
+	mr, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	if err := s.Store(ctx, &store.Session{Token: "token-1", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	mr.FastForward(2 * time.Minute)
+
+	if _, err := s.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after TTL, got %v", err)
+	}
+}
+
+func TestSessionStore_SkipsExpiredSessions(t *testing.T) {
+	mr, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+
+	past := time.Now().Add(-time.Minute)
+	if err := s.Store(context.Background(), &store.Session{Token: "token-1", ExpiresAt: past}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+	if len(mr.Keys()) != 0 {
+		t.Errorf("Expired sessions should not be written, got keys %v", mr.Keys())
+	}
+}
+
+func TestRevokedSessionStore_SharedAcrossReplicas(t *testing.T) {
+	mr, client := newTestClient(t)
+	ctx := context.Background()
+
+	// Two replicas of the same app share revocations, another app does not
+	replicaA := NewRevokedSessionStore(client, "app-1")
+	replicaB := NewRevokedSessionStore(client, "app-1")
+	otherApp := NewRevokedSessionStore(client, "app-2")
+
+	now := time.Now()
+	if err := replicaA.Store(ctx, &store.RevokedSession{Token: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	if _, err := replicaB.Get(ctx, "token-1"); err != nil {
+		t.Errorf("Revocation should be visible to other replicas, got %v", err)
+	}
+	if _, err := otherApp.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Revocation should not leak across apps, got %v", err)
+	}
+
+	mr.FastForward(2 * time.Hour)
+
+	if _, err := replicaB.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after TTL, got %v", err)
+	}
+}
+
+func TestSessionStore_DeleteByPhone(t *testing.T) {
+	mr, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "short", UserPhone: "+1234567890", ExpiresAt: now.Add(time.Minute)})
+	s.Store(ctx, &store.Session{Token: "long", UserPhone: "+1234567890", ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "other", UserPhone: "+1987654321", ExpiresAt: now.Add(time.Hour)})
+
+	// The index must outlive its longest session
+	if ttl := mr.TTL("rauth:app-1:phone:+1234567890"); ttl <= time.Minute {
+		t.Errorf("Phone index should expire with the longest session, got TTL %v", ttl)
+	}
+
+	// Expired members are skipped
+	mr.FastForward(2 * time.Minute)
+
+	tokens, err := s.DeleteByPhone(ctx, "+1234567890")
+	if err != nil {
+		t.Fatalf("DeleteByPhone returned error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0] != "long" {
+		t.Errorf("Expected [long], got %v", tokens)
+	}
+	if _, err := s.Get(ctx, "long"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after DeleteByPhone, got %v", err)
+	}
+	if _, err := s.Get(ctx, "other"); err != nil {
+		t.Errorf("Other phones should keep their sessions, got %v", err)
+	}
+
+	if tokens, err := s.DeleteByPhone(ctx, "+1234567890"); err != nil || len(tokens) != 0 {
+		t.Errorf("Expected no sessions left, got %v, %v", tokens, err)
+	}
+}
+
+func TestSessionStore_ListAndCountSessions(t *testing.T) {
+	_, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "newer", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "older", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "other", UserPhone: "+1987654321", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Delete(ctx, "newer")
+
+	sessions, err := s.ListSessions(ctx, "+1234567890")
+	if err != nil {
+		t.Fatalf("ListSessions returned error: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Token != "older" {
+		t.Errorf("Expected [older], got %+v", sessions)
+	}
+	if count, err := s.CountSessions(ctx, "+1234567890"); err != nil || count != 1 {
+		t.Errorf("Expected 1 session, got %d, %v", count, err)
+	}
+}
+
+func TestSessionStore_TouchExtendsExpiry(t *testing.T) {
+	mr, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "token-1", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Minute)})
+
+	if err := s.Touch(ctx, "token-1", now, now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("Touch returned error: %v", err)
+	}
+	if ttl := mr.TTL("rauth:app-1:session:token-1"); ttl <= time.Minute {
+		t.Errorf("Touch should extend the key TTL, got %v", ttl)
+	}
+
+	got, err := s.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if !got.LastSeenAt.Equal(now) || !got.ExpiresAt.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("Expected touched session, got %+v", got)
+	}
+
+	if err := s.Touch(ctx, "unknown", now, now.Add(time.Minute)); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestRevokedSessionStore_NotBeforeOnlyMovesForward(t *testing.T) {
+	_, client := newTestClient(t)
+	s := NewRevokedSessionStore(client, "app-1")
+	ctx := context.Background()
+
+	if notBefore, err := s.GetNotBefore(ctx, ""); err != nil || !notBefore.IsZero() {
+		t.Errorf("Expected no epoch, got %v, %v", notBefore, err)
+	}
+
+	now := time.Now()
+	s.SetNotBefore(ctx, "", now)
+	s.SetNotBefore(ctx, "", now.Add(-time.Hour))
+	s.SetNotBefore(ctx, "+1234567890", now.Add(-time.Minute))
+
+	if notBefore, _ := s.GetNotBefore(ctx, ""); !notBefore.Equal(now) {
+		t.Errorf("Expected the later global epoch %v, got %v", now, notBefore)
+	}
+	if notBefore, _ := s.GetNotBefore(ctx, "+1234567890"); !notBefore.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Unexpected phone epoch %v", notBefore)
+	}
+}
+
+func TestSessionStore_PhoneIndexLivesAsLongAsItsLongestSession(t *testing.T) {
+	mr, client := newTestClient(t)
+	s := NewSessionStore(client, "app-1")
+	ctx := context.Background()
+	indexKey := "rauth:app-1:phone:+1234567890"
+
+	now := time.Now()
+	for _, session := range []struct {
+		token   string
+		ttl     time.Duration
+		wantTTL time.Duration
+	}{
+		{"token-1", time.Hour, time.Hour},
+		{"token-2", 15 * time.Minute, time.Hour}, // a shorter session keeps the index TTL
+		{"token-3", 2 * time.Hour, 2 * time.Hour},
+	} {
+		if err := s.Store(ctx, &store.Session{Token: session.token, UserPhone: "+1234567890", ExpiresAt: now.Add(session.ttl)}); err != nil {
+			t.Fatalf("Store(%s) returned error: %v", session.token, err)
+		}
+		if ttl := mr.TTL(indexKey); ttl <= session.wantTTL-time.Minute || ttl > session.wantTTL {
+			t.Errorf("After %s, expected the index to expire in %v, got %v", session.token, session.wantTTL, ttl)
+		}
+	}
+}
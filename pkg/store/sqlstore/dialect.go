@@ -0,0 +1,68 @@
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour spoken by the database
+type Dialect string
+
+// Supported dialects
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// valid reports whether the dialect is supported
+func (d Dialect) valid() bool {
+	switch d {
+	case Postgres, MySQL, SQLite:
+		return true
+	}
+	return false
+}
+
+// rebind rewrites '?' placeholders into the dialect's placeholder syntax
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// upsert builds an insert statement that replaces the row on a primary key conflict
+func (d Dialect) upsert(table string, keys, columns []string) string {
+	all := append(append([]string{}, keys...), columns...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(all)), ", ")
+	query := "INSERT INTO " + table + " (" + strings.Join(all, ", ") + ") VALUES (" + placeholders + ")"
+
+	updates := make([]string, len(columns))
+	for i, column := range columns {
+		if d == MySQL {
+			updates[i] = column + " = VALUES(" + column + ")"
+		} else {
+			updates[i] = column + " = excluded." + column
+		}
+	}
+
+	if d == MySQL {
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	} else {
+		query += " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	return d.rebind(query)
+}
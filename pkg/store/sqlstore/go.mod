@@ -0,0 +1,10 @@
+module github.com/RAuth-IO/rauth-provider-go/pkg/store/sqlstore
+
+go 1.19
+
+require (
+	github.com/RAuth-IO/rauth-provider-go v1.0.0
+	github.com/mattn/go-sqlite3 v1.14.22
+)
+
+replace github.com/RAuth-IO/rauth-provider-go => ../../../
@@ -0,0 +1,157 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a versioned set of schema statements
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrations lists the schema history in order; never edit an applied entry, append a new one.
+// Timestamps are stored as Unix nanoseconds so every dialect can index and compare them.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE rauth_sessions (
+				app_id VARCHAR(255) NOT NULL,
+				token VARCHAR(255) NOT NULL,
+				user_phone VARCHAR(64) NOT NULL,
+				created_at BIGINT NOT NULL,
+				expires_at BIGINT NOT NULL,
+				PRIMARY KEY (app_id, token)
+			)`,
+			`CREATE INDEX rauth_sessions_expires_at ON rauth_sessions (expires_at)`,
+			`CREATE TABLE rauth_revoked_sessions (
+				app_id VARCHAR(255) NOT NULL,
+				token VARCHAR(255) NOT NULL,
+				revoked_at BIGINT NOT NULL,
+				expires_at BIGINT NOT NULL,
+				PRIMARY KEY (app_id, token)
+			)`,
+			`CREATE INDEX rauth_revoked_sessions_expires_at ON rauth_revoked_sessions (expires_at)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`CREATE INDEX rauth_sessions_phone ON rauth_sessions (app_id, user_phone)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`CREATE TABLE rauth_revocation_epochs (
+				app_id VARCHAR(255) NOT NULL,
+				scope VARCHAR(255) NOT NULL,
+				not_before BIGINT NOT NULL,
+				PRIMARY KEY (app_id, scope)
+			)`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE rauth_sessions ADD COLUMN client_ip VARCHAR(64) NOT NULL DEFAULT ''`,
+			`ALTER TABLE rauth_sessions ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT ''`,
+			`ALTER TABLE rauth_sessions ADD COLUMN device_label VARCHAR(255) NOT NULL DEFAULT ''`,
+			`ALTER TABLE rauth_sessions ADD COLUMN channel VARCHAR(32) NOT NULL DEFAULT ''`,
+			`ALTER TABLE rauth_sessions ADD COLUMN last_seen_at BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`ALTER TABLE rauth_sessions ADD COLUMN verified_at BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`ALTER TABLE rauth_sessions ADD COLUMN upstream_expires_at BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			`ALTER TABLE rauth_revoked_sessions ADD COLUMN source VARCHAR(32) NOT NULL DEFAULT ''`,
+			`ALTER TABLE rauth_revoked_sessions ADD COLUMN reason VARCHAR(255) NOT NULL DEFAULT ''`,
+		},
+	},
+}
+
+// Migrate creates or upgrades the schema used by the stores.
+// It records applied versions in rauth_schema_migrations and is safe to call on every start,
+// including from several instances at once: a migration that fails because another
+// instance applied it first is skipped.
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	if !dialect.valid() {
+		return fmt.Errorf("unsupported SQL dialect: %q", dialect)
+	}
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rauth_schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, dialect, m); err != nil {
+			if applied, verr := schemaVersion(ctx, db); verr == nil && applied >= m.version {
+				continue
+			}
+			return fmt.Errorf("failed to apply migration %d: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion returns the highest applied migration version
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM rauth_schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration runs a migration and records it in a single transaction.
+// MySQL commits DDL implicitly, so a failure there may leave a partial migration.
+func applyMigration(ctx context.Context, db *sql.DB, dialect Dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		dialect.rebind(`INSERT INTO rauth_schema_migrations (version, applied_at) VALUES (?, ?)`),
+		m.version, time.Now().UnixNano(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
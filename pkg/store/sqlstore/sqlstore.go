@@ -0,0 +1,329 @@
+// Package sqlstore provides database/sql session and revoked session stores
+// for Postgres, MySQL and SQLite.
+//
+// Call Migrate once at start-up to create or upgrade the schema before using
+// the stores. Records are scoped by Rauth app ID, expiry is kept in indexed
+// expires_at columns used by Cleanup, and every query honours the caller's context.
+// The stores do not own the *sql.DB; close it yourself after the provider.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/phone"
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+// SessionStore implements store.SessionRepository on top of database/sql
+type SessionStore struct {
+	db      *sql.DB
+	dialect Dialect
+	appID   string
+}
+
+// NewSessionStore creates a SQL session store for the given app
+func NewSessionStore(db *sql.DB, dialect Dialect, appID string) *SessionStore {
+	return &SessionStore{
+		db:      db,
+		dialect: dialect,
+		appID:   appID,
+	}
+}
+
+// sessionColumns lists the columns read by scanSession, in order
+const sessionColumns = `token, user_phone, created_at, expires_at, verified_at, upstream_expires_at,
+	client_ip, user_agent, device_label, channel, last_seen_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSession reads a session selected with sessionColumns
+func scanSession(row rowScanner) (*store.Session, error) {
+	var createdAt, expiresAt, verifiedAt, upstreamExpiresAt, lastSeenAt int64
+	session := &store.Session{}
+	if err := row.Scan(
+		&session.Token, &session.UserPhone, &createdAt, &expiresAt, &verifiedAt, &upstreamExpiresAt,
+		&session.ClientIP, &session.UserAgent, &session.DeviceLabel, &session.Channel, &lastSeenAt,
+	); err != nil {
+		return nil, err
+	}
+
+	session.CreatedAt = fromUnixNano(createdAt)
+	session.ExpiresAt = fromUnixNano(expiresAt)
+	session.VerifiedAt = fromUnixNano(verifiedAt)
+	session.UpstreamExpiresAt = fromUnixNano(upstreamExpiresAt)
+	session.LastSeenAt = fromUnixNano(lastSeenAt)
+	return session, nil
+}
+
+// toUnixNano converts a time to Unix nanoseconds, mapping the zero time to 0
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano converts Unix nanoseconds to a time, mapping 0 to the zero time
+func fromUnixNano(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// truncate shortens a value to at most size bytes to fit a VARCHAR column of
+// the given size, cutting on a rune boundary so that the value stays valid UTF-8
+func truncate(value string, size int) string {
+	if len(value) <= size {
+		return value
+	}
+	for size > 0 && !utf8.RuneStart(value[size]) {
+		size--
+	}
+	return value[:size]
+}
+
+// phoneKey returns the value of the indexed user_phone column for a phone number, see phone.Key
+func phoneKey(number string) string {
+	return phone.Key(number)
+}
+
+// Store stores a session, replacing any existing record for the token
+func (s *SessionStore) Store(ctx context.Context, session *store.Session) error {
+	query := s.dialect.upsert("rauth_sessions",
+		[]string{"app_id", "token"},
+		[]string{"user_phone", "created_at", "expires_at", "verified_at", "upstream_expires_at", "client_ip", "user_agent", "device_label", "channel", "last_seen_at"},
+	)
+
+	_, err := s.db.ExecContext(ctx, query,
+		s.appID, session.Token,
+		phoneKey(session.UserPhone), toUnixNano(session.CreatedAt), toUnixNano(session.ExpiresAt),
+		toUnixNano(session.VerifiedAt), toUnixNano(session.UpstreamExpiresAt),
+		truncate(session.ClientIP, 64), truncate(session.UserAgent, 512), truncate(session.DeviceLabel, 255),
+		truncate(session.Channel, 32), toUnixNano(session.LastSeenAt),
+	)
+	return err
+}
+
+// Get retrieves an unexpired session by token
+func (s *SessionStore) Get(ctx context.Context, token string) (*store.Session, error) {
+	query := s.dialect.rebind(`SELECT ` + sessionColumns + ` FROM rauth_sessions
+		WHERE app_id = ? AND token = ? AND expires_at > ?`)
+
+	session, err := scanSession(s.db.QueryRowContext(ctx, query, s.appID, token, time.Now().UnixNano()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Delete removes a session
+func (s *SessionStore) Delete(ctx context.Context, token string) error {
+	query := s.dialect.rebind(`DELETE FROM rauth_sessions WHERE app_id = ? AND token = ?`)
+
+	_, err := s.db.ExecContext(ctx, query, s.appID, token)
+	return err
+}
+
+// DeleteByPhone removes every session of a phone number and returns the tokens of the unexpired ones
+func (s *SessionStore) DeleteByPhone(ctx context.Context, phone string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(`SELECT token FROM rauth_sessions
+		WHERE app_id = ? AND user_phone = ? AND expires_at > ?`),
+		s.appID, phoneKey(phone), time.Now().UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM rauth_sessions WHERE app_id = ? AND user_phone = ?`),
+		s.appID, phoneKey(phone),
+	); err != nil {
+		return nil, err
+	}
+
+	return tokens, tx.Commit()
+}
+
+// ListSessions returns the unexpired sessions of a phone number, oldest first
+func (s *SessionStore) ListSessions(ctx context.Context, phone string) ([]*store.Session, error) {
+	query := s.dialect.rebind(`SELECT ` + sessionColumns + ` FROM rauth_sessions
+		WHERE app_id = ? AND user_phone = ? AND expires_at > ? ORDER BY created_at`)
+
+	rows, err := s.db.QueryContext(ctx, query, s.appID, phoneKey(phone), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*store.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch sets the last seen time and expiry of an unexpired session
+func (s *SessionStore) Touch(ctx context.Context, token string, lastSeenAt, expiresAt time.Time) error {
+	query := s.dialect.rebind(`UPDATE rauth_sessions SET last_seen_at = ?, expires_at = ?
+		WHERE app_id = ? AND token = ? AND expires_at > ?`)
+
+	result, err := s.db.ExecContext(ctx, query,
+		toUnixNano(lastSeenAt), toUnixNano(expiresAt), s.appID, token, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrSessionNotFound
+	}
+	return nil
+}
+
+// CountSessions returns the number of unexpired sessions of a phone number
+func (s *SessionStore) CountSessions(ctx context.Context, phone string) (int, error) {
+	query := s.dialect.rebind(`SELECT COUNT(*) FROM rauth_sessions
+		WHERE app_id = ? AND user_phone = ? AND expires_at > ?`)
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, s.appID, phoneKey(phone), time.Now().UnixNano()).Scan(&count)
+	return count, err
+}
+
+// Cleanup removes expired sessions
+func (s *SessionStore) Cleanup(ctx context.Context) error {
+	query := s.dialect.rebind(`DELETE FROM rauth_sessions WHERE expires_at <= ?`)
+
+	_, err := s.db.ExecContext(ctx, query, time.Now().UnixNano())
+	return err
+}
+
+// RevokedSessionStore implements store.RevokedSessionRepository on top of database/sql
+type RevokedSessionStore struct {
+	db      *sql.DB
+	dialect Dialect
+	appID   string
+}
+
+// NewRevokedSessionStore creates a SQL revoked session store for the given app
+func NewRevokedSessionStore(db *sql.DB, dialect Dialect, appID string) *RevokedSessionStore {
+	return &RevokedSessionStore{
+		db:      db,
+		dialect: dialect,
+		appID:   appID,
+	}
+}
+
+// Store stores a revoked session, replacing any existing record for the token
+func (s *RevokedSessionStore) Store(ctx context.Context, revokedSession *store.RevokedSession) error {
+	query := s.dialect.upsert("rauth_revoked_sessions",
+		[]string{"app_id", "token"},
+		[]string{"revoked_at", "expires_at", "source", "reason"},
+	)
+
+	_, err := s.db.ExecContext(ctx, query,
+		s.appID, revokedSession.Token,
+		revokedSession.RevokedAt.UnixNano(), revokedSession.ExpiresAt.UnixNano(),
+		truncate(revokedSession.Source, 32), truncate(revokedSession.Reason, 255),
+	)
+	return err
+}
+
+// Get retrieves an unexpired revoked session by token
+func (s *RevokedSessionStore) Get(ctx context.Context, token string) (*store.RevokedSession, error) {
+	query := s.dialect.rebind(`SELECT revoked_at, expires_at, source, reason FROM rauth_revoked_sessions
+		WHERE app_id = ? AND token = ? AND expires_at > ?`)
+
+	var revokedAt, expiresAt int64
+	revokedSession := &store.RevokedSession{Token: token}
+	err := s.db.QueryRowContext(ctx, query, s.appID, token, time.Now().UnixNano()).
+		Scan(&revokedAt, &expiresAt, &revokedSession.Source, &revokedSession.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revokedSession.RevokedAt = time.Unix(0, revokedAt)
+	revokedSession.ExpiresAt = time.Unix(0, expiresAt)
+	return revokedSession, nil
+}
+
+// SetNotBefore revokes every session of scope created before notBefore,
+// keeping the stored epoch if it is later
+func (s *RevokedSessionStore) SetNotBefore(ctx context.Context, scope string, notBefore time.Time) error {
+	var query string
+	if s.dialect == MySQL {
+		query = `INSERT INTO rauth_revocation_epochs (app_id, scope, not_before) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE not_before = GREATEST(not_before, VALUES(not_before))`
+	} else {
+		query = `INSERT INTO rauth_revocation_epochs (app_id, scope, not_before) VALUES (?, ?, ?)
+			ON CONFLICT (app_id, scope) DO UPDATE SET not_before = excluded.not_before
+			WHERE rauth_revocation_epochs.not_before < excluded.not_before`
+	}
+
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(query), s.appID, scope, notBefore.UnixNano())
+	return err
+}
+
+// GetNotBefore returns the epoch of scope, or the zero time when none is set
+func (s *RevokedSessionStore) GetNotBefore(ctx context.Context, scope string) (time.Time, error) {
+	query := s.dialect.rebind(`SELECT not_before FROM rauth_revocation_epochs WHERE app_id = ? AND scope = ?`)
+
+	var notBefore int64
+	err := s.db.QueryRowContext(ctx, query, s.appID, scope).Scan(&notBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, notBefore), nil
+}
+
+// Cleanup removes expired revoked sessions
+func (s *RevokedSessionStore) Cleanup(ctx context.Context) error {
+	query := s.dialect.rebind(`DELETE FROM rauth_revoked_sessions WHERE expires_at <= ?`)
+
+	_, err := s.db.ExecContext(ctx, query, time.Now().UnixNano())
+	return err
+}
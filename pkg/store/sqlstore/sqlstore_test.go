@@ -0,0 +1,371 @@
+//go:build cgo
+
+// The tests use the cgo SQLite driver; CGO_ENABLED=0 builds skip them.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/RAuth-IO/rauth-provider-go/pkg/store"
+)
+
+var (
+	_ store.SessionRepository        = (*SessionStore)(nil)
+	_ store.RevokedSessionRepository = (*RevokedSessionStore)(nil)
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "rauth.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Migrate(context.Background(), db, SQLite); err != nil {
+		t.Fatalf("Migrate returned error: %v", err)
+	}
+	return db
+}
+
+func TestMigrate_IsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	if err := Migrate(ctx, db, SQLite); err != nil {
+		t.Fatalf("Second Migrate returned error: %v", err)
+	}
+
+	version, err := schemaVersion(ctx, db)
+	if err != nil {
+		t.Fatalf("schemaVersion returned error: %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("Expected schema version %d, got %d", want, version)
+	}
+}
+
+func TestMigrate_ConcurrentStarts(t *testing.T) {
+	// Wait for the database lock rather than failing, like a server database would
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "rauth.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Migrate(context.Background(), db, SQLite)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Migrate returned error: %v", err)
+		}
+	}
+}
+
+func TestMigrate_RejectsUnknownDialect(t *testing.T) {
+	if err := Migrate(context.Background(), nil, Dialect("oracle")); err == nil {
+		t.Error("Expected an error for an unsupported dialect")
+	}
+}
+
+func TestSessionStore_StoreGetDelete(t *testing.T) {
+	s := NewSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	session := &store.Session{
+		Token:     "token-1",
+		UserPhone: "+1234567890",
+		CreatedAt: now,
+		ExpiresAt: now.Add(15 * time.Minute),
+	}
+	if err := s.Store(ctx, session); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	// Storing again must replace rather than conflict
+	if err := s.Store(ctx, session); err != nil {
+		t.Fatalf("Second Store returned error: %v", err)
+	}
+
+	got, err := s.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.UserPhone != session.UserPhone || !got.CreatedAt.Equal(session.CreatedAt) || !got.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("Get returned %+v, want %+v", got, session)
+	}
+
+	if err := s.Delete(ctx, "token-1"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := s.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after Delete, got %v", err)
+	}
+}
+
+func TestSessionStore_CleanupRemovesExpired(t *testing.T) {
+	db := newTestDB(t)
+	s := NewSessionStore(db, SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "expired", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)})
+	s.Store(ctx, &store.Session{Token: "active", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	if _, err := s.Get(ctx, "expired"); err != store.ErrSessionNotFound {
+		t.Errorf("Expired sessions should not be returned, got %v", err)
+	}
+
+	if err := s.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup returned error: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM rauth_sessions`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count sessions: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 session after Cleanup, got %d", count)
+	}
+}
+
+func TestSessionStore_DeleteByPhone(t *testing.T) {
+	s := NewSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "token-1", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "token-2", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "other", UserPhone: "+1987654321", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	tokens, err := s.DeleteByPhone(ctx, "+1234567890")
+	if err != nil {
+		t.Fatalf("DeleteByPhone returned error: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Errorf("Expected 2 tokens, got %v", tokens)
+	}
+	if _, err := s.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after DeleteByPhone, got %v", err)
+	}
+	if _, err := s.Get(ctx, "other"); err != nil {
+		t.Errorf("Other phones should keep their sessions, got %v", err)
+	}
+}
+
+func TestSessionStore_ListAndCountSessions(t *testing.T) {
+	s := NewSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "newer", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "older", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)})
+	s.Store(ctx, &store.Session{Token: "expired", UserPhone: "+1234567890", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)})
+
+	sessions, err := s.ListSessions(ctx, "+1234567890")
+	if err != nil {
+		t.Fatalf("ListSessions returned error: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Token != "older" || sessions[1].Token != "newer" {
+		t.Errorf("Expected [older newer], got %+v", sessions)
+	}
+	if count, err := s.CountSessions(ctx, "+1234567890"); err != nil || count != 2 {
+		t.Errorf("Expected 2 sessions, got %d, %v", count, err)
+	}
+}
+
+func TestSessionStore_PersistsMetadata(t *testing.T) {
+	s := NewSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	metadata := store.SessionMetadata{
+		ClientIP:    "203.0.113.7",
+		UserAgent:   "Mozilla/5.0",
+		DeviceLabel: "Work laptop",
+		Channel:     store.ChannelWhatsApp,
+		LastSeenAt:  now,
+	}
+	s.Store(ctx, &store.Session{Token: "token-1", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour), SessionMetadata: metadata})
+	s.Store(ctx, &store.Session{Token: "token-2", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	session, err := s.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if session.ClientIP != metadata.ClientIP || session.UserAgent != metadata.UserAgent ||
+		session.DeviceLabel != metadata.DeviceLabel || session.Channel != metadata.Channel {
+		t.Errorf("Expected metadata %+v, got %+v", metadata, session.SessionMetadata)
+	}
+	if !session.LastSeenAt.Equal(now) {
+		t.Errorf("Expected LastSeenAt %v, got %v", now, session.LastSeenAt)
+	}
+
+	session, err = s.Get(ctx, "token-2")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if !session.LastSeenAt.IsZero() {
+		t.Errorf("Expected zero LastSeenAt, got %v", session.LastSeenAt)
+	}
+}
+
+func TestSessionStore_TouchExtendsExpiry(t *testing.T) {
+	s := NewSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Store(ctx, &store.Session{Token: "token-1", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(time.Minute), VerifiedAt: now})
+	s.Store(ctx, &store.Session{Token: "expired", UserPhone: "+1234567890", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)})
+
+	if err := s.Touch(ctx, "token-1", now, now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("Touch returned error: %v", err)
+	}
+
+	got, err := s.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if !got.LastSeenAt.Equal(now) || !got.ExpiresAt.Equal(now.Add(10*time.Minute)) || !got.VerifiedAt.Equal(now) {
+		t.Errorf("Expected touched session, got %+v", got)
+	}
+
+	if err := s.Touch(ctx, "expired", now, now.Add(time.Minute)); err != store.ErrSessionNotFound {
+		t.Errorf("Expired sessions should not be revived, got %v", err)
+	}
+}
+
+func TestSessionStore_RespectsContext(t *testing.T) {
+	s := NewSessionStore(newTestDB(t), SQLite, "app-1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.Get(ctx, "token-1"); err == nil || err == store.ErrSessionNotFound {
+		t.Errorf("Expected a context error, got %v", err)
+	}
+}
+
+func TestRevokedSessionStore_ScopedByApp(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	app1 := NewRevokedSessionStore(db, SQLite, "app-1")
+	app2 := NewRevokedSessionStore(db, SQLite, "app-2")
+
+	now := time.Now()
+	if err := app1.Store(ctx, &store.RevokedSession{Token: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	got, err := app1.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if !got.RevokedAt.Equal(now) {
+		t.Errorf("Expected RevokedAt %v, got %v", now, got.RevokedAt)
+	}
+	if _, err := app2.Get(ctx, "token-1"); err != store.ErrSessionNotFound {
+		t.Errorf("Revocation should not leak across apps, got %v", err)
+	}
+}
+
+func TestRevokedSessionStore_PersistsSourceAndReason(t *testing.T) {
+	s := NewRevokedSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	now := time.Now()
+	if err := s.Store(ctx, &store.RevokedSession{Token: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour), Source: "admin", Reason: "account locked"}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	got, err := s.Get(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.Source != "admin" || got.Reason != "account locked" {
+		t.Errorf("Expected source and reason to round-trip, got %+v", got)
+	}
+}
+
+func TestRevokedSessionStore_NotBeforeOnlyMovesForward(t *testing.T) {
+	s := NewRevokedSessionStore(newTestDB(t), SQLite, "app-1")
+	ctx := context.Background()
+
+	if notBefore, err := s.GetNotBefore(ctx, ""); err != nil || !notBefore.IsZero() {
+		t.Errorf("Expected no epoch, got %v, %v", notBefore, err)
+	}
+
+	now := time.Now()
+	for _, notBefore := range []time.Time{now, now.Add(-time.Hour)} {
+		if err := s.SetNotBefore(ctx, "", notBefore); err != nil {
+			t.Fatalf("SetNotBefore returned error: %v", err)
+		}
+	}
+
+	if notBefore, _ := s.GetNotBefore(ctx, ""); !notBefore.Equal(now) {
+		t.Errorf("Expected the later epoch %v, got %v", now, notBefore)
+	}
+	if notBefore, _ := s.GetNotBefore(ctx, "+1234567890"); !notBefore.IsZero() {
+		t.Errorf("Phone epochs should be separate from the global one, got %v", notBefore)
+	}
+}
+
+func TestTruncate_KeepsValidUTF8(t *testing.T) {
+	tests := []struct {
+		value string
+		size  int
+		want  string
+	}{
+		{"short", 32, "short"},
+		{"abcdef", 3, "abc"},
+		{"aéb", 2, "a"},
+		{"aéb", 3, "aé"},
+		{"日本語", 5, "日"},
+	}
+
+	for _, test := range tests {
+		got := truncate(test.value, test.size)
+		if got != test.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d): expected %q, got %q", test.value, test.size, test.want, got)
+		}
+	}
+}
+
+func TestDialect_Queries(t *testing.T) {
+	if got := Postgres.rebind("a = ? AND b = ?"); got != "a = $1 AND b = $2" {
+		t.Errorf("Unexpected Postgres rebind: %s", got)
+	}
+
+	mysql := MySQL.upsert("t", []string{"k"}, []string{"v"})
+	if want := "INSERT INTO t (k, v) VALUES (?, ?) ON DUPLICATE KEY UPDATE v = VALUES(v)"; mysql != want {
+		t.Errorf("Unexpected MySQL upsert:\n got %s\nwant %s", mysql, want)
+	}
+
+	postgres := Postgres.upsert("t", []string{"k"}, []string{"v"})
+	if want := "INSERT INTO t (k, v) VALUES ($1, $2) ON CONFLICT (k) DO UPDATE SET v = excluded.v"; postgres != want {
+		t.Errorf("Unexpected Postgres upsert:\n got %s\nwant %s", postgres, want)
+	}
+}
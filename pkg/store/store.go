@@ -0,0 +1,68 @@
+// Package store exposes the session storage contracts used by rauthprovider,
+// so applications can plug in their own backends (Redis, SQL or custom)
+// through rauthprovider.WithSessionStore and rauthprovider.WithRevokedStore.
+//
+// Implementations must be safe for concurrent use and must return
+// ErrSessionNotFound from Get when no record exists for a token.
+// Stores that also implement Closer are closed when the provider is closed.
+package store
+
+import (
+	"github.com/RAuth-IO/rauth-provider-go/internal/domain"
+	"github.com/RAuth-IO/rauth-provider-go/internal/infrastructure"
+)
+
+// Session represents a verified user session cached by the provider
+type Session = domain.Session
+
+// SessionMetadata holds optional details recorded when a session is first verified
+type SessionMetadata = domain.SessionMetadata
+
+// Verification channels reported in SessionMetadata.Channel
+const (
+	ChannelWhatsApp = domain.ChannelWhatsApp
+	ChannelSMS      = domain.ChannelSMS
+)
+
+// RevokedSession represents a revoked session record
+type RevokedSession = domain.RevokedSession
+
+// SessionRepository defines the interface for session storage operations
+type SessionRepository = domain.SessionRepository
+
+// RevokedSessionRepository defines the interface for revoked session storage operations
+type RevokedSessionRepository = domain.RevokedSessionRepository
+
+// RevocationMessage describes a revocation propagated between provider instances
+type RevocationMessage = domain.RevocationMessage
+
+// RevocationBroadcaster propagates revocations to every provider instance
+type RevocationBroadcaster = domain.RevocationBroadcaster
+
+// Closer is implemented by stores that must be flushed or released on shutdown
+type Closer = domain.Closer
+
+// Errors returned by store implementations
+var (
+	// ErrSessionNotFound is returned by Get when no record exists for a token
+	ErrSessionNotFound = domain.ErrSessionNotFound
+
+	// ErrSessionExpired may be returned by SessionRepository.Get for an expired session
+	ErrSessionExpired = domain.ErrSessionExpired
+)
+
+// NewMemorySessionStore creates the in-memory session store used by default
+func NewMemorySessionStore() SessionRepository {
+	return infrastructure.NewSessionStore()
+}
+
+// NewMemoryRevokedSessionStore creates the in-memory revoked session store used by default
+func NewMemoryRevokedSessionStore() RevokedSessionRepository {
+	return infrastructure.NewRevokedSessionStore()
+}
+
+// NewInProcessBroadcaster creates a broadcaster that propagates revocations
+// between providers running in the same process
+func NewInProcessBroadcaster() RevocationBroadcaster {
+	return infrastructure.NewInProcessBroadcaster()
+}